@@ -0,0 +1,577 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/nitrictech/cli/pkg/cloud/env"
+	"github.com/nitrictech/cli/pkg/cloud/gateway"
+	"github.com/nitrictech/cli/pkg/cloud/keyvalue"
+	"github.com/nitrictech/cli/pkg/cloud/schedules"
+	"github.com/nitrictech/cli/pkg/cloud/topics"
+	"github.com/nitrictech/cli/pkg/dashboard"
+	"github.com/nitrictech/cli/pkg/project"
+	"github.com/nitrictech/cli/pkg/view/tui"
+)
+
+var localAddress string
+
+var localCmd = &cobra.Command{
+	Use:   "local",
+	Short: "Interact with a running local cloud (started with nitric start or nitric run)",
+	Long: `Interact with a running local cloud (started with nitric start or nitric run).
+
+Commands are sent to the trigger address of the local cloud running in the project directory, or the address given with --address.`,
+	Example: `nitric local topics delayed
+nitric local topics release [eventId]
+nitric local topics cancel [eventId]
+nitric local schedules
+nitric local schedules pause [scheduleName]
+nitric local schedules resume [scheduleName]
+nitric local schedules next [scheduleName]
+nitric local clock
+nitric local clock advance 24h
+nitric local clock reset
+nitric local history topics
+nitric local replay topics [recordId]
+nitric local kv export -o stores.json
+nitric local kv import stores.json
+`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if cmd.Root().PersistentPreRun != nil {
+			cmd.Root().PersistentPreRun(cmd, args)
+		}
+	},
+}
+
+// localCloudAddress returns the trigger address of the running local cloud, as given by --address or recorded by the local cloud of the project
+func localCloudAddress() (string, error) {
+	if localAddress != "" {
+		return localAddress, nil
+	}
+
+	address, err := os.ReadFile(env.LOCAL_TRIGGER_ADDRESS_FILE)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no running local cloud found for this project, run nitric start or nitric run from the project directory, or provide its trigger address with --address")
+		}
+
+		return "", err
+	}
+
+	return strings.TrimSpace(string(address)), nil
+}
+
+// localCloudRequest sends a request to the trigger address of a running local cloud, returning the response body
+func localCloudRequest(method string, path string, body io.Reader) ([]byte, error) {
+	address, err := localCloudAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, "http://"+address+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the local cloud at %s, ensure nitric start or nitric run is running: %w", address, err)
+	}
+	defer resp.Body.Close()
+
+	// another server may be listening on the address, e.g. when the local cloud has stopped and its port has been reused
+	if resp.Header.Get(gateway.TriggerServerHeader) == "" {
+		return nil, fmt.Errorf("%s isn't the trigger address of a nitric local cloud, ensure nitric start or nitric run is running", address)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(respBody)))
+	}
+
+	return respBody, nil
+}
+
+var localTopicsCmd = &cobra.Command{
+	Use:   "topics",
+	Short: "Manage the topics of a running local cloud",
+	Long:  `Manage the topics of a running local cloud.`,
+}
+
+var localDelayedEventsCmd = &cobra.Command{
+	Use:     "delayed",
+	Short:   "List the events pending delayed delivery",
+	Long:    `List the events published with a delay that are pending delivery.`,
+	Example: `nitric local topics delayed`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		body, err := localCloudRequest(http.MethodGet, "/delayed-events", nil)
+		tui.CheckErr(err)
+
+		delayedEvents := []topics.DelayedEventInfo{}
+
+		err = json.Unmarshal(body, &delayedEvents)
+		tui.CheckErr(err)
+
+		if len(delayedEvents) == 0 {
+			fmt.Println("No delayed events pending")
+			return
+		}
+
+		for _, delayedEvent := range delayedEvents {
+			status := fmt.Sprintf("due in %s", time.Until(delayedEvent.DeliverAt).Round(time.Second))
+			if delayedEvent.WaitingForSubscribers {
+				status = "waiting for subscribers"
+			}
+
+			if delayedEvent.Retry {
+				status = fmt.Sprintf("retry %d to %s, %s", delayedEvent.Attempts+1, strings.Join(delayedEvent.Services, ", "), status)
+			}
+
+			fmt.Printf("%s  %s  %s  %s\n", delayedEvent.Id, delayedEvent.TopicName, status, delayedEvent.Payload)
+		}
+	},
+}
+
+var localReleaseDelayedEventCmd = &cobra.Command{
+	Use:     "release [eventId]",
+	Short:   "Deliver a delayed event immediately",
+	Long:    `Deliver an event pending delayed delivery immediately.`,
+	Example: `nitric local topics release 5f0d0cd4-3f4e-4c52-9d2e-0c1f3cfa1b62`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := localCloudRequest(http.MethodPost, "/delayed-events/"+url.PathEscape(args[0])+"/release", nil)
+		tui.CheckErr(err)
+
+		fmt.Printf("Released delayed event %s\n", args[0])
+	},
+}
+
+var localCancelDelayedEventCmd = &cobra.Command{
+	Use:     "cancel [eventId]",
+	Short:   "Cancel a delayed event",
+	Long:    `Cancel an event pending delayed delivery, it will not be delivered.`,
+	Example: `nitric local topics cancel 5f0d0cd4-3f4e-4c52-9d2e-0c1f3cfa1b62`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := localCloudRequest(http.MethodDelete, "/delayed-events/"+url.PathEscape(args[0]), nil)
+		tui.CheckErr(err)
+
+		fmt.Printf("Cancelled delayed event %s\n", args[0])
+	},
+}
+
+var nextRunCount int
+
+var localSchedulesCmd = &cobra.Command{
+	Use:     "schedules",
+	Short:   "List the schedules of a running local cloud",
+	Long:    `List the schedules of a running local cloud, including whether they are paused and when they will next run.`,
+	Example: `nitric local schedules`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		body, err := localCloudRequest(http.MethodGet, "/schedules", nil)
+		tui.CheckErr(err)
+
+		scheduleInfo := []schedules.ScheduleInfo{}
+
+		err = json.Unmarshal(body, &scheduleInfo)
+		tui.CheckErr(err)
+
+		if len(scheduleInfo) == 0 {
+			fmt.Println("No schedules registered")
+			return
+		}
+
+		for _, schedule := range scheduleInfo {
+			cadence := fmt.Sprintf("%s (%s)", schedule.Expression, schedule.Timezone)
+			if schedule.Rate != "" {
+				cadence = "every " + schedule.Rate
+			}
+
+			status := "not running"
+			if schedule.Paused {
+				status = "paused"
+			} else if len(schedule.NextRuns) > 0 {
+				status = "next run " + schedule.NextRuns[0].Format(time.RFC3339)
+			}
+
+			fmt.Printf("%s  %s  %s  %s\n", schedule.Name, schedule.ServiceName, cadence, status)
+		}
+	},
+}
+
+var localPauseScheduleCmd = &cobra.Command{
+	Use:     "pause [scheduleName]",
+	Short:   "Pause a schedule",
+	Long:    `Pause a schedule, it will not run until it is resumed. Paused schedules can still be triggered manually.`,
+	Example: `nitric local schedules pause nightly-report`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := localCloudRequest(http.MethodPost, "/schedules/"+url.PathEscape(args[0])+"/pause", nil)
+		tui.CheckErr(err)
+
+		fmt.Printf("Paused schedule %s\n", args[0])
+	},
+}
+
+var localResumeScheduleCmd = &cobra.Command{
+	Use:     "resume [scheduleName]",
+	Short:   "Resume a paused schedule",
+	Long:    `Resume a paused schedule from its next run time, runs missed while it was paused are skipped.`,
+	Example: `nitric local schedules resume nightly-report`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := localCloudRequest(http.MethodPost, "/schedules/"+url.PathEscape(args[0])+"/resume", nil)
+		tui.CheckErr(err)
+
+		fmt.Printf("Resumed schedule %s\n", args[0])
+	},
+}
+
+var localScheduleNextRunsCmd = &cobra.Command{
+	Use:     "next [scheduleName]",
+	Short:   "List the next run times of a schedule",
+	Long:    `List the next run times of a schedule, according to the local cloud clock, in the timezone of the schedule.`,
+	Example: `nitric local schedules next nightly-report --count 10`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		body, err := localCloudRequest(http.MethodGet, fmt.Sprintf("/schedules/%s/next?count=%d", url.PathEscape(args[0]), nextRunCount), nil)
+		tui.CheckErr(err)
+
+		nextRuns := []time.Time{}
+
+		err = json.Unmarshal(body, &nextRuns)
+		tui.CheckErr(err)
+
+		for _, nextRun := range nextRuns {
+			fmt.Println(nextRun.Format(time.RFC3339))
+		}
+	},
+}
+
+func printClock(clock schedules.ClockState) {
+	if clock.Offset == 0 {
+		fmt.Printf("Clock is at %s (real time)\n", clock.Now.Format(time.RFC3339))
+		return
+	}
+
+	fmt.Printf("Clock is at %s (%s ahead of real time)\n", clock.Now.Format(time.RFC3339), time.Duration(clock.Offset)*time.Millisecond)
+}
+
+var localClockCmd = &cobra.Command{
+	Use:   "clock",
+	Short: "Show the virtual clock used to run local schedules",
+	Long: `Show the virtual clock used to run local schedules.
+
+The clock can be advanced to run schedules as if time had passed, e.g. to test nightly or month-end jobs.`,
+	Example: `nitric local clock`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		body, err := localCloudRequest(http.MethodGet, "/clock", nil)
+		tui.CheckErr(err)
+
+		clock := schedules.ClockState{}
+
+		err = json.Unmarshal(body, &clock)
+		tui.CheckErr(err)
+
+		printClock(clock)
+	},
+}
+
+var localAdvanceClockCmd = &cobra.Command{
+	Use:   "advance [duration]",
+	Short: "Advance the virtual clock, running any schedules that fall due",
+	Long: `Advance the virtual clock, running every schedule that would have run in the skipped window in order.
+
+The duration is a Go duration string, such as 90m, 24h or 720h.`,
+	Example: `nitric local clock advance 24h`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		duration, err := time.ParseDuration(args[0])
+		tui.CheckErr(err)
+
+		body, err := localCloudRequest(http.MethodPost, "/clock/advance?duration="+url.QueryEscape(duration.String()), nil)
+		tui.CheckErr(err)
+
+		result := schedules.ClockAdvanceResult{}
+
+		err = json.Unmarshal(body, &result)
+		tui.CheckErr(err)
+
+		fmt.Printf("Advanced clock by %s, ran %d schedules\n", duration, result.Runs)
+		printClock(result.Clock)
+	},
+}
+
+var localResetClockCmd = &cobra.Command{
+	Use:     "reset",
+	Short:   "Reset the virtual clock to real time",
+	Long:    `Reset the virtual clock to real time, schedules are not run when the clock is reset.`,
+	Example: `nitric local clock reset`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := localCloudRequest(http.MethodPost, "/clock/reset", nil)
+		tui.CheckErr(err)
+
+		fmt.Println("Reset clock to real time")
+	},
+}
+
+// replayableHistory lists the history record types that can be replayed
+var replayableHistory = []string{string(dashboard.TOPIC), string(dashboard.BATCHJOBS), string(dashboard.API)}
+
+var localHistoryCmd = &cobra.Command{
+	Use:   "history [topics|jobs|apis]",
+	Short: "List the recorded history of the project",
+	Long: `List the recorded topic messages, batch job submissions or API requests of the project.
+
+The ids listed can be used to replay a record with nitric local replay.`,
+	Example:   `nitric local history topics`,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: replayableHistory,
+	Run: func(cmd *cobra.Command, args []string) {
+		proj, err := project.FromFile(afero.NewOsFs(), "")
+		tui.CheckErr(err)
+
+		printRecord := func(id string, recordTime int64, summary string) {
+			if id == "" {
+				// recorded before records had ids, so it can't be replayed
+				id = "-"
+			}
+
+			fmt.Printf("%s  %s  %s\n", id, time.UnixMilli(recordTime).Format(time.DateTime), summary)
+		}
+
+		switch dashboard.RecordType(args[0]) {
+		case dashboard.TOPIC:
+			history, err := dashboard.ReadHistoryRecords[dashboard.TopicHistoryItem](proj.Directory, dashboard.TOPIC)
+			tui.CheckErr(err)
+
+			for _, record := range history {
+				printRecord(record.Id, record.Time, fmt.Sprintf("%s  %s", record.Event.Name, record.Event.Payload))
+			}
+		case dashboard.BATCHJOBS:
+			history, err := dashboard.ReadHistoryRecords[dashboard.BatchHistoryItem](proj.Directory, dashboard.BATCHJOBS)
+			tui.CheckErr(err)
+
+			for _, record := range history {
+				printRecord(record.Id, record.Time, fmt.Sprintf("%s  %s", record.Event.Name, record.Event.Payload))
+			}
+		case dashboard.API:
+			history, err := dashboard.ReadHistoryRecords[dashboard.ApiHistoryItem](proj.Directory, dashboard.API)
+			tui.CheckErr(err)
+
+			for _, record := range history {
+				printRecord(record.Id, record.Time, fmt.Sprintf("%s  %s %s  %d", record.Event.Api, record.Event.Request.Method, record.Event.Request.Path, record.Event.Response.Status))
+			}
+		}
+	},
+}
+
+var localReplayCmd = &cobra.Command{
+	Use:   "replay [topics|jobs|apis] [recordId]",
+	Short: "Replay a recorded topic message, batch job submission or API request",
+	Long: `Replay a recorded topic message, batch job submission or API request against the running local cloud.
+
+Use nitric local history to list the ids of recorded events.`,
+	Example: `nitric local replay topics 5f0d0cd4-3f4e-4c52-9d2e-0c1f3cfa1b62
+nitric local replay apis 0b6a3e1c-4f4a-4c2e-8d6b-2f1a7c9e5d31`,
+	Args:      cobra.MatchAll(cobra.ExactArgs(2), func(cmd *cobra.Command, args []string) error { return cobra.OnlyValidArgs(cmd, args[:1]) }),
+	ValidArgs: replayableHistory,
+	Run: func(cmd *cobra.Command, args []string) {
+		proj, err := project.FromFile(afero.NewOsFs(), "")
+		tui.CheckErr(err)
+
+		recordType := dashboard.RecordType(args[0])
+		id := args[1]
+
+		switch recordType {
+		case dashboard.TOPIC:
+			record, err := dashboard.FindHistoryRecord[dashboard.TopicHistoryItem](proj.Directory, recordType, id)
+			tui.CheckErr(err)
+
+			_, err = localCloudRequest(http.MethodPost, "/topics/"+url.PathEscape(record.Event.Name), strings.NewReader(record.Event.Payload))
+			tui.CheckErr(err)
+
+			fmt.Printf("Replayed message to topic %s\n", record.Event.Name)
+		case dashboard.BATCHJOBS:
+			record, err := dashboard.FindHistoryRecord[dashboard.BatchHistoryItem](proj.Directory, recordType, id)
+			tui.CheckErr(err)
+
+			_, err = localCloudRequest(http.MethodPost, "/jobs/"+url.PathEscape(record.Event.Name), strings.NewReader(record.Event.Payload))
+			tui.CheckErr(err)
+
+			fmt.Printf("Replayed submission of job %s\n", record.Event.Name)
+		case dashboard.API:
+			record, err := dashboard.FindHistoryRecord[dashboard.ApiHistoryItem](proj.Directory, recordType, id)
+			tui.CheckErr(err)
+
+			replayRequest, err := json.Marshal(record.Event.ReplayRequest())
+			tui.CheckErr(err)
+
+			body, err := localCloudRequest(http.MethodPost, "/apis/"+url.PathEscape(record.Event.Api)+"/replay", bytes.NewReader(replayRequest))
+			tui.CheckErr(err)
+
+			fmt.Printf("Replayed %s %s to api %s\n%s\n", record.Event.Request.Method, record.Event.Request.Path, record.Event.Api, string(body))
+		}
+	},
+}
+
+var (
+	kvExportOutput string
+	kvExportFormat string
+)
+
+var localKeyValueCmd = &cobra.Command{
+	Use:   "kv",
+	Short: "Export and import the local key/value stores",
+	Long: `Export and import the local key/value stores of the project, e.g. to share a reproducible state with a teammate.
+
+The stores are read from disk, so the local cloud must be stopped. Use the dashboard to export or import while it's running.`,
+}
+
+var localKeyValueExportCmd = &cobra.Command{
+	Use:   "export [storeName...]",
+	Short: "Export local key/value stores to a JSON or NDJSON file",
+	Long: `Export local key/value stores to a JSON or NDJSON file, every store is exported when no stores are given.
+
+Keys are exported with their store, so an export of several stores can be imported in one go. Expired keys aren't exported.`,
+	Example: `nitric local kv export -o stores.json
+nitric local kv export orders customers -o stores.ndjson`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := project.FromFile(afero.NewOsFs(), "")
+		tui.CheckErr(err)
+
+		format := keyvalue.ExportJSON
+
+		// infer the format from the output file, unless it's given
+		if cmd.Flags().Changed("format") {
+			format, err = keyvalue.ParseExportFormat(kvExportFormat)
+			tui.CheckErr(err)
+		} else if ext := filepath.Ext(kvExportOutput); ext == ".ndjson" || ext == ".jsonl" {
+			format = keyvalue.ExportNDJSON
+		}
+
+		kvService, err := keyvalue.NewBoltService(keyvalue.NewBoltServiceOpts{})
+		tui.CheckErr(err)
+
+		defer kvService.Close()
+
+		out := os.Stdout
+		// the summary is written to stderr when the export is written to stdout, so the export can be piped
+		summary := os.Stderr
+
+		if kvExportOutput != "" {
+			out, err = os.Create(kvExportOutput)
+			tui.CheckErr(err)
+
+			defer out.Close()
+
+			summary = os.Stdout
+		}
+
+		count, err := kvService.Export(out, format, args...)
+		tui.CheckErr(err)
+
+		fmt.Fprintf(summary, "Exported %d keys\n", count)
+	},
+}
+
+var localKeyValueImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import a JSON or NDJSON export into the local key/value stores",
+	Long: `Import a JSON or NDJSON export into the local key/value stores, overwriting keys that already exist.
+
+Every value is validated before any keys are imported, so an invalid export leaves the stores unchanged.`,
+	Example: `nitric local kv import stores.json`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := project.FromFile(afero.NewOsFs(), "")
+		tui.CheckErr(err)
+
+		file, err := os.Open(args[0])
+		tui.CheckErr(err)
+
+		defer file.Close()
+
+		kvService, err := keyvalue.NewBoltService(keyvalue.NewBoltServiceOpts{})
+		tui.CheckErr(err)
+
+		defer kvService.Close()
+
+		count, err := kvService.Import(file)
+		tui.CheckErr(err)
+
+		fmt.Printf("Imported %d keys\n", count)
+	},
+}
+
+func init() {
+	localCmd.PersistentFlags().StringVar(&localAddress, "address", "", "the trigger address of the running local cloud, defaults to the address recorded by the local cloud of the project")
+
+	// Topics
+	localTopicsCmd.AddCommand(localDelayedEventsCmd)
+	localTopicsCmd.AddCommand(localReleaseDelayedEventCmd)
+	localTopicsCmd.AddCommand(localCancelDelayedEventCmd)
+	localCmd.AddCommand(localTopicsCmd)
+
+	// Schedules
+	localScheduleNextRunsCmd.Flags().IntVarP(&nextRunCount, "count", "c", 5, "the number of run times to list")
+	localSchedulesCmd.AddCommand(localPauseScheduleCmd)
+	localSchedulesCmd.AddCommand(localResumeScheduleCmd)
+	localSchedulesCmd.AddCommand(localScheduleNextRunsCmd)
+	localCmd.AddCommand(localSchedulesCmd)
+
+	// Clock
+	localClockCmd.AddCommand(localAdvanceClockCmd)
+	localClockCmd.AddCommand(localResetClockCmd)
+	localCmd.AddCommand(localClockCmd)
+
+	// History
+	localCmd.AddCommand(localHistoryCmd)
+	localCmd.AddCommand(localReplayCmd)
+
+	// Key/value stores
+	localKeyValueExportCmd.Flags().StringVarP(&kvExportOutput, "output", "o", "", "the file to write the export to, defaults to stdout")
+	localKeyValueExportCmd.Flags().StringVarP(&kvExportFormat, "format", "f", "json", "the export format, json or ndjson, inferred from the output file when not given")
+	localKeyValueCmd.AddCommand(localKeyValueExportCmd)
+	localKeyValueCmd.AddCommand(localKeyValueImportCmd)
+	localCmd.AddCommand(localKeyValueCmd)
+
+	// Add Local Commands
+	rootCmd.AddCommand(localCmd)
+}
@@ -21,6 +21,7 @@ import (
 	"io"
 	"sync"
 
+	"github.com/samber/lo"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
@@ -86,6 +87,28 @@ func (lc *LocalCloud) Stop() {
 	if err != nil {
 		logger.Errorf("Error stopping databases: %s", err.Error())
 	}
+
+	err = lc.Queues.Close()
+	if err != nil {
+		logger.Errorf("Error closing queues: %s", err.Error())
+	}
+
+	err = lc.Topics.Close()
+	if err != nil {
+		logger.Errorf("Error closing topics: %s", err.Error())
+	}
+
+	lc.Schedules.Stop()
+
+	err = lc.Storage.Close()
+	if err != nil {
+		logger.Errorf("Error closing storage: %s", err.Error())
+	}
+
+	err = lc.KeyValue.Close()
+	if err != nil {
+		logger.Errorf("Error closing key/value stores: %s", err.Error())
+	}
 }
 
 func (lc *LocalCloud) AddBatch(batchName string) (int, error) {
@@ -249,7 +272,9 @@ type LocalCloudOptions struct {
 }
 
 func New(projectName string, opts LocalCloudOptions) (*LocalCloud, error) {
-	localTopics, err := topics.NewLocalTopicsService()
+	localTopics, err := topics.NewLocalTopicsService(topics.NewLocalTopicsServiceOpts{
+		LocalConfig: opts.LocalConfig,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -260,23 +285,41 @@ func New(projectName string, opts LocalCloudOptions) (*LocalCloud, error) {
 	}
 
 	localStorage, err := storage.NewLocalStorageService(storage.StorageOptions{
-		AccessKey: "dummykey",
-		SecretKey: "dummysecret",
+		AccessKey:   "dummykey",
+		SecretKey:   "dummysecret",
+		LocalConfig: opts.LocalConfig,
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	localResources := resources.NewLocalResourcesService()
+
+	// declared buckets can be listed through the S3 API before anything is written to them
+	localResources.SubscribeToState(func(lrs resources.LocalResourcesState) {
+		localStorage.SetDeclaredBuckets(lo.Keys(lrs.Buckets.GetAll()))
+	})
+
 	localBatch := batch.NewLocalBatchService()
-	localSchedules := schedules.NewLocalSchedulesService(localResources.LogServiceError)
+	localSchedules := schedules.NewLocalSchedulesService(schedules.NewLocalSchedulesServiceOpts{
+		ErrorLogger: localResources.LogServiceError,
+		LocalConfig: opts.LocalConfig,
+	})
 	localHttpProxy := http.NewLocalHttpProxyService()
 
+	localQueueService, err := queues.NewLocalQueuesService(queues.NewLocalQueuesServiceOpts{
+		LocalConfig: opts.LocalConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	localGateway, err := gateway.NewGateway(gateway.NewGatewayOpts{
 		TLSCredentials: opts.TLSCredentials,
 		LogWriter:      opts.LogWriter,
 		LocalConfig:    opts.LocalConfig,
 		BatchPlugin:    localBatch,
+		QueuesPlugin:   localQueueService,
 	})
 	if err != nil {
 		return nil, err
@@ -293,12 +336,9 @@ func New(projectName string, opts LocalCloudOptions) (*LocalCloud, error) {
 		opts.LogWriter = io.Discard
 	}
 
-	keyvalueService, err := keyvalue.NewBoltService()
-	if err != nil {
-		return nil, err
-	}
-
-	localQueueService, err := queues.NewLocalQueuesService()
+	keyvalueService, err := keyvalue.NewBoltService(keyvalue.NewBoltServiceOpts{
+		LocalConfig: opts.LocalConfig,
+	})
 	if err != nil {
 		return nil, err
 	}
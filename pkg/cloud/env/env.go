@@ -34,6 +34,12 @@ var (
 	LOCAL_BUCKETS_DIR      = env.GetEnv("LOCAL_BUCKETS_DIR", filepath.Join(NITRIC_LOCAL_RUN_DIR.String(), "./buckets/"))
 	LOCAL_SEAWEED_LOGS_DIR = env.GetEnv("LOCAL_SEAWEED_LOGS_DIR", filepath.Join(NITRIC_LOCAL_RUN_DIR.String(), "./logs/"))
 	LOCAL_SECRETS_DIR      = env.GetEnv("LOCAL_SECRETS_DIR", filepath.Join(NITRIC_LOCAL_RUN_DIR.String(), "./secrets/"))
+	LOCAL_QUEUES_DIR       = env.GetEnv("LOCAL_QUEUES_DIR", filepath.Join(NITRIC_LOCAL_RUN_DIR.String(), "./queues/"))
+	LOCAL_TOPICS_DIR       = env.GetEnv("LOCAL_TOPICS_DIR", filepath.Join(NITRIC_LOCAL_RUN_DIR.String(), "./topics/"))
+	LOCAL_STORAGE_DIR      = env.GetEnv("LOCAL_STORAGE_DIR", filepath.Join(NITRIC_LOCAL_RUN_DIR.String(), "./storage/"))
 )
 
+// File the running local cloud records its trigger address in, so nitric local commands can find it
+var LOCAL_TRIGGER_ADDRESS_FILE = filepath.Join(NITRIC_LOCAL_RUN_DIR.String(), "./trigger-address")
+
 var MAX_WORKERS = env.GetEnv("MAX_WORKERS", "300")
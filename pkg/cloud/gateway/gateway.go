@@ -24,7 +24,10 @@ import (
 	"log"
 	"net"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -39,7 +42,9 @@ import (
 
 	"github.com/nitrictech/cli/pkg/cloud/apis"
 	"github.com/nitrictech/cli/pkg/cloud/batch"
+	"github.com/nitrictech/cli/pkg/cloud/env"
 	"github.com/nitrictech/cli/pkg/cloud/http"
+	"github.com/nitrictech/cli/pkg/cloud/queues"
 	"github.com/nitrictech/cli/pkg/cloud/schedules"
 	"github.com/nitrictech/cli/pkg/cloud/topics"
 	"github.com/nitrictech/cli/pkg/cloud/websockets"
@@ -94,6 +99,7 @@ type LocalGatewayService struct {
 	topicsPlugin     *topics.LocalTopicsAndSubscribersService
 	schedulesPlugin  *schedules.LocalSchedulesService
 	batchPlugin      *batch.LocalBatchService
+	queuesPlugin     *queues.LocalQueuesService
 	serviceListener  net.Listener
 
 	localConfig localconfig.LocalConfiguration
@@ -112,6 +118,27 @@ type LocalGatewayService struct {
 
 var _ gateway.GatewayService = &LocalGatewayService{}
 
+// TriggerServerHeader is set on every response from the trigger server, so clients can tell it apart from other servers
+const TriggerServerHeader = "X-Nitric-Local-Trigger"
+
+// recordTriggerAddress writes the trigger address to the local run directory, for nitric local commands to read
+func (s *LocalGatewayService) recordTriggerAddress() error {
+	err := os.MkdirAll(filepath.Dir(env.LOCAL_TRIGGER_ADDRESS_FILE), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(env.LOCAL_TRIGGER_ADDRESS_FILE, []byte(s.GetTriggerAddress()), 0o600)
+}
+
+// removeTriggerAddress removes the recorded trigger address, unless another local cloud has since recorded its own
+func (s *LocalGatewayService) removeTriggerAddress() {
+	address, err := os.ReadFile(env.LOCAL_TRIGGER_ADDRESS_FILE)
+	if err == nil && string(address) == s.GetTriggerAddress() {
+		_ = os.Remove(env.LOCAL_TRIGGER_ADDRESS_FILE)
+	}
+}
+
 // GetTriggerAddress - Returns the base address built-in nitric services, like schedules and topics, will be exposed on.
 func (s *LocalGatewayService) GetTriggerAddress() string {
 	if s.serviceListener != nil {
@@ -421,25 +448,26 @@ func (s *LocalGatewayService) handleWebsocketRequest(socketName string) func(ctx
 	}
 }
 
-func (s *LocalGatewayService) handleTopicRequest(ctx *fasthttp.RequestCtx) {
-	topicName := ctx.UserValue("name").(string)
-
-	// Get the incoming data as JSON
+// parseJsonPayload parses a JSON object payload into a struct
+func parseJsonPayload(body []byte) (*structpb.Struct, error) {
 	payload := map[string]interface{}{}
 
-	err := json.Unmarshal(ctx.Request.Body(), &payload)
+	err := json.Unmarshal(body, &payload)
 	if err != nil {
-		ctx.Error(fmt.Sprintf("Error parsing JSON: %v", err), 400)
-		return
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
 	}
 
-	structPayload, err := structpb.NewStruct(payload)
+	st, err := structpb.NewStruct(payload)
 	if err != nil {
-		ctx.Error(fmt.Sprintf("Error serializing topic message from payload: %v", err), 400)
-		return
+		return nil, fmt.Errorf("error serializing message from payload: %w", err)
 	}
 
-	_, err = s.topicsPlugin.Publish(ctx, &topicspb.TopicPublishRequest{
+	return st, nil
+}
+
+// PublishTopicMessage publishes a JSON payload to a topic, as if it was published by a service
+func (s *LocalGatewayService) PublishTopicMessage(ctx context.Context, topicName string, structPayload *structpb.Struct) error {
+	_, err := s.topicsPlugin.Publish(ctx, &topicspb.TopicPublishRequest{
 		TopicName: topicName,
 		Message: &topicspb.TopicMessage{
 			Content: &topicspb.TopicMessage_StructPayload{
@@ -447,6 +475,21 @@ func (s *LocalGatewayService) handleTopicRequest(ctx *fasthttp.RequestCtx) {
 			},
 		},
 	})
+
+	return err
+}
+
+func (s *LocalGatewayService) handleTopicRequest(ctx *fasthttp.RequestCtx) {
+	topicName := ctx.UserValue("name").(string)
+
+	// Get the incoming data as JSON
+	structPayload, err := parseJsonPayload(ctx.Request.Body())
+	if err != nil {
+		ctx.Error(err.Error(), 400)
+		return
+	}
+
+	err = s.PublishTopicMessage(ctx, topicName, structPayload)
 	if err != nil {
 		ctx.Error(fmt.Sprintf("Error handling topic request: %v", err), 500)
 		return
@@ -475,36 +518,285 @@ func (s *LocalGatewayService) handleSchedulesTrigger(ctx *fasthttp.RequestCtx) {
 	ctx.SuccessString("text/plain", "Successfully triggered schedule")
 }
 
+// SubmitBatchJob submits a job with a JSON payload, as if it was submitted by a service
+func (s *LocalGatewayService) SubmitBatchJob(ctx context.Context, jobName string, st *structpb.Struct) error {
+	jobSubmitRequest := &batchpb.JobSubmitRequest{
+		JobName: jobName,
+		Data:    &batchpb.JobData{Data: &batchpb.JobData_Struct{Struct: st}},
+	}
+
+	_, err := s.batchPlugin.SubmitJob(ctx, jobSubmitRequest)
+
+	return err
+}
+
 func (s *LocalGatewayService) handleBatchJobTrigger(ctx *fasthttp.RequestCtx) {
 	jobName := ctx.UserValue("name").(string)
 
 	// Get the incoming data as JobData_Struct
-	payload := map[string]interface{}{}
+	st, err := parseJsonPayload(ctx.Request.Body())
+	if err != nil {
+		ctx.Error(err.Error(), 400)
+		return
+	}
 
-	err := json.Unmarshal(ctx.Request.Body(), &payload)
+	err = s.SubmitBatchJob(context.Background(), jobName, st)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error handling batch job trigger: %v", err), 500)
+		return
+	}
+
+	ctx.SuccessString("text/plain", "Successfully triggered job")
+}
+
+// ApiReplayRequest - a previously recorded API request to send again
+type ApiReplayRequest struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   map[string][]string `json:"query"`
+	Headers map[string][]string `json:"headers"`
+	Body    []byte              `json:"body"`
+}
+
+// ReplayApiRequest sends a previously recorded request to an API, handling it exactly as if it was received by the API's server
+func (s *LocalGatewayService) ReplayApiRequest(apiName string, replayRequest *ApiReplayRequest) (*fasthttp.Response, error) {
+	s.lock.RLock()
+	apiExists := lo.Contains(s.apis, apiName)
+	s.lock.RUnlock()
+
+	if !apiExists {
+		return nil, fmt.Errorf("api %s not found", apiName)
+	}
+
+	req := &fasthttp.Request{}
+	req.Header.SetMethod(replayRequest.Method)
+	req.SetRequestURI(replayRequest.Path)
+	req.URI().SetQueryString(url.Values(replayRequest.Query).Encode())
+
+	for key, values := range replayRequest.Headers {
+		// the content length is set from the body
+		if strings.EqualFold(key, "Content-Length") {
+			continue
+		}
+
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	req.SetBody(replayRequest.Body)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(req, nil, nil)
+
+	s.handleApiHttpRequest(apiName)(ctx)
+
+	return &ctx.Response, nil
+}
+
+func (s *LocalGatewayService) handleApiReplay(ctx *fasthttp.RequestCtx) {
+	apiName := ctx.UserValue("name").(string)
+
+	replayRequest := &ApiReplayRequest{}
+
+	err := json.Unmarshal(ctx.Request.Body(), replayRequest)
 	if err != nil {
 		ctx.Error(fmt.Sprintf("Error parsing JSON: %v", err), 400)
 		return
 	}
 
-	st, err := structpb.NewStruct(payload)
+	resp, err := s.ReplayApiRequest(apiName, replayRequest)
 	if err != nil {
-		ctx.Error(fmt.Sprintf("Error serializing job message from payload: %v", err), 400)
+		ctx.Error(fmt.Sprintf("Error replaying API request: %v", err), 400)
 		return
 	}
 
-	jobSubmitRequest := &batchpb.JobSubmitRequest{
-		JobName: jobName,
-		Data:    &batchpb.JobData{Data: &batchpb.JobData_Struct{Struct: st}},
+	resp.CopyTo(&ctx.Response)
+}
+
+func (s *LocalGatewayService) handleDelayedEventsList(ctx *fasthttp.RequestCtx) {
+	delayedEvents, err := s.topicsPlugin.GetDelayedEvents()
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error reading delayed events: %v", err), 500)
+		return
 	}
 
-	_, err = s.batchPlugin.SubmitJob(context.Background(), jobSubmitRequest)
+	body, err := json.Marshal(delayedEvents)
 	if err != nil {
-		ctx.Error(fmt.Sprintf("Error handling batch job trigger: %v", err), 500)
+		ctx.Error(fmt.Sprintf("Error serializing delayed events: %v", err), 500)
 		return
 	}
 
-	ctx.SuccessString("text/plain", "Successfully triggered job")
+	ctx.Success("application/json", body)
+}
+
+func (s *LocalGatewayService) handleDelayedEventRelease(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+
+	err := s.topicsPlugin.ReleaseDelayedEvent(id)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error releasing delayed event: %v", err), 400)
+		return
+	}
+
+	ctx.SuccessString("text/plain", "Successfully released delayed event")
+}
+
+func (s *LocalGatewayService) handleDelayedEventCancel(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+
+	err := s.topicsPlugin.CancelDelayedEvent(id)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error cancelling delayed event: %v", err), 400)
+		return
+	}
+
+	ctx.SuccessString("text/plain", "Successfully cancelled delayed event")
+}
+
+// queryCount parses the optional count query arg, used to limit the number of schedule run times returned
+func queryCount(ctx *fasthttp.RequestCtx, defaultCount int) (int, error) {
+	countArg := ctx.QueryArgs().Peek("count")
+	if len(countArg) == 0 {
+		return defaultCount, nil
+	}
+
+	count, err := strconv.Atoi(string(countArg))
+	if err != nil || count < 1 || count > 100 {
+		return 0, fmt.Errorf("invalid count %q, must be a number between 1 and 100", countArg)
+	}
+
+	return count, nil
+}
+
+func (s *LocalGatewayService) handleSchedulesList(ctx *fasthttp.RequestCtx) {
+	count, err := queryCount(ctx, 1)
+	if err != nil {
+		ctx.Error(err.Error(), 400)
+		return
+	}
+
+	body, err := json.Marshal(s.schedulesPlugin.GetScheduleInfo(count))
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error serializing schedules: %v", err), 500)
+		return
+	}
+
+	ctx.Success("application/json", body)
+}
+
+func (s *LocalGatewayService) handleSchedulePause(ctx *fasthttp.RequestCtx) {
+	scheduleName := ctx.UserValue("name").(string)
+
+	err := s.schedulesPlugin.PauseSchedule(scheduleName)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error pausing schedule: %v", err), 400)
+		return
+	}
+
+	ctx.SuccessString("text/plain", "Successfully paused schedule")
+}
+
+func (s *LocalGatewayService) handleScheduleResume(ctx *fasthttp.RequestCtx) {
+	scheduleName := ctx.UserValue("name").(string)
+
+	err := s.schedulesPlugin.ResumeSchedule(scheduleName)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error resuming schedule: %v", err), 400)
+		return
+	}
+
+	ctx.SuccessString("text/plain", "Successfully resumed schedule")
+}
+
+func (s *LocalGatewayService) handleScheduleNextRuns(ctx *fasthttp.RequestCtx) {
+	scheduleName := ctx.UserValue("name").(string)
+
+	count, err := queryCount(ctx, 5)
+	if err != nil {
+		ctx.Error(err.Error(), 400)
+		return
+	}
+
+	nextRuns, err := s.schedulesPlugin.NextRuns(scheduleName, count)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error reading next runs: %v", err), 400)
+		return
+	}
+
+	body, err := json.Marshal(nextRuns)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error serializing next runs: %v", err), 500)
+		return
+	}
+
+	ctx.Success("application/json", body)
+}
+
+func (s *LocalGatewayService) handleClockState(ctx *fasthttp.RequestCtx) {
+	body, err := json.Marshal(s.schedulesPlugin.GetClockState())
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error serializing clock: %v", err), 500)
+		return
+	}
+
+	ctx.Success("application/json", body)
+}
+
+func (s *LocalGatewayService) handleClockAdvance(ctx *fasthttp.RequestCtx) {
+	duration, err := time.ParseDuration(string(ctx.QueryArgs().Peek("duration")))
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Invalid duration %q, must be a duration such as 90m or 24h", ctx.QueryArgs().Peek("duration")), 400)
+		return
+	}
+
+	runs, err := s.schedulesPlugin.AdvanceClock(duration)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error advancing clock: %v", err), 400)
+		return
+	}
+
+	body, err := json.Marshal(schedules.ClockAdvanceResult{
+		Runs:  runs,
+		Clock: s.schedulesPlugin.GetClockState(),
+	})
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error serializing clock: %v", err), 500)
+		return
+	}
+
+	ctx.Success("application/json", body)
+}
+
+func (s *LocalGatewayService) handleClockReset(ctx *fasthttp.RequestCtx) {
+	s.schedulesPlugin.ResetClock()
+
+	ctx.SuccessString("text/plain", "Successfully reset clock")
+}
+
+func (s *LocalGatewayService) handleQueueLeaseExtension(ctx *fasthttp.RequestCtx) {
+	queueName := ctx.UserValue("name").(string)
+	leaseId := ctx.UserValue("leaseId").(string)
+
+	timeout := 0
+
+	if timeoutArg := ctx.QueryArgs().Peek("timeout"); len(timeoutArg) > 0 {
+		var err error
+
+		timeout, err = strconv.Atoi(string(timeoutArg))
+		if err != nil || timeout < 0 {
+			ctx.Error(fmt.Sprintf("Invalid timeout %q, must be a positive number of seconds", timeoutArg), 400)
+			return
+		}
+	}
+
+	lease, err := s.queuesPlugin.ExtendLease(ctx, queueName, leaseId, time.Duration(timeout)*time.Second)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("Error extending lease: %v", err), 400)
+		return
+	}
+
+	ctx.SuccessString("text/plain", fmt.Sprintf("Successfully extended lease until %s", lease.Expiry.Format(time.RFC3339)))
 }
 
 func (s *LocalGatewayService) refreshApis(apiState apis.State) {
@@ -806,6 +1098,21 @@ const (
 	topicPath    = "/topics/" + nameParam
 	schedulePath = "/schedules/" + nameParam
 	batchPath    = "/jobs/" + nameParam
+	leasePath    = "/queues/" + nameParam + "/leases/{leaseId}"
+	replayPath   = "/apis/" + nameParam + "/replay"
+
+	delayedEventsPath       = "/delayed-events"
+	delayedEventPath        = delayedEventsPath + "/{id}"
+	delayedEventReleasePath = delayedEventPath + "/release"
+
+	schedulesPath        = "/schedules"
+	schedulePausePath    = schedulePath + "/pause"
+	scheduleResumePath   = schedulePath + "/resume"
+	scheduleNextRunsPath = schedulePath + "/next"
+
+	clockPath        = "/clock"
+	clockAdvancePath = clockPath + "/advance"
+	clockResetPath   = clockPath + "/reset"
 )
 
 func (s *LocalGatewayService) GetTopicTriggerUrl(topicName string) string {
@@ -836,13 +1143,29 @@ func (s *LocalGatewayService) Start(opts *gateway.GatewayStartOpts) error {
 	r.POST(topicPath, s.handleTopicRequest)
 	r.POST(schedulePath, s.handleSchedulesTrigger)
 	r.POST(batchPath, s.handleBatchJobTrigger)
+	r.POST(leasePath, s.handleQueueLeaseExtension)
+	r.POST(replayPath, s.handleApiReplay)
+	r.GET(delayedEventsPath, s.handleDelayedEventsList)
+	r.POST(delayedEventReleasePath, s.handleDelayedEventRelease)
+	r.DELETE(delayedEventPath, s.handleDelayedEventCancel)
+	r.GET(schedulesPath, s.handleSchedulesList)
+	r.POST(schedulePausePath, s.handleSchedulePause)
+	r.POST(scheduleResumePath, s.handleScheduleResume)
+	r.GET(scheduleNextRunsPath, s.handleScheduleNextRuns)
+	r.GET(clockPath, s.handleClockState)
+	r.POST(clockAdvancePath, s.handleClockAdvance)
+	r.POST(clockResetPath, s.handleClockReset)
 
 	s.serviceServer = &fasthttp.Server{
 		ReadTimeout:     time.Second * 1,
 		IdleTimeout:     time.Second * 1,
 		CloseOnShutdown: true,
 		ReadBufferSize:  8192,
-		Handler:         r.Handler,
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			r.Handler(ctx)
+			// set after the handler, as errors reset the response headers
+			ctx.Response.Header.Set(TriggerServerHeader, "true")
+		},
 	}
 
 	s.serviceListener, err = netx.GetNextListener()
@@ -850,6 +1173,11 @@ func (s *LocalGatewayService) Start(opts *gateway.GatewayStartOpts) error {
 		return err
 	}
 
+	err = s.recordTriggerAddress()
+	if err != nil {
+		return err
+	}
+
 	if apiPlugin, ok := s.options.ApiPlugin.(*apis.LocalApiGatewayService); ok {
 		apiPlugin.SubscribeToState(func(state apis.State) {
 			s.refreshApis(state)
@@ -909,6 +1237,8 @@ func (s *LocalGatewayService) Stop() error {
 	}
 
 	if s.serviceServer != nil {
+		s.removeTriggerAddress()
+
 		return s.serviceServer.Shutdown()
 	}
 
@@ -920,6 +1250,7 @@ type NewGatewayOpts struct {
 	LogWriter      io.Writer
 	LocalConfig    localconfig.LocalConfiguration
 	BatchPlugin    *batch.LocalBatchService
+	QueuesPlugin   *queues.LocalQueuesService
 }
 
 // Create new HTTP gateway
@@ -931,5 +1262,6 @@ func NewGateway(opts NewGatewayOpts) (*LocalGatewayService, error) {
 		logWriter:         opts.LogWriter,
 		localConfig:       opts.LocalConfig,
 		batchPlugin:       opts.BatchPlugin,
+		queuesPlugin:      opts.QueuesPlugin,
 	}, nil
 }
@@ -0,0 +1,319 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyvalue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	kvstorepb "github.com/nitrictech/nitric/core/pkg/proto/kvstore/v1"
+)
+
+// ExportFormat is the file format of exported keys
+type ExportFormat string
+
+const (
+	// ExportJSON is a JSON array of keys
+	ExportJSON ExportFormat = "json"
+	// ExportNDJSON is a key per line, suited to large stores and line based tools
+	ExportNDJSON ExportFormat = "ndjson"
+)
+
+// ExportedKey is a key in an export, it records its store so exports of several stores can be imported in one go
+type ExportedKey struct {
+	Store string                 `json:"store"`
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+	// When the key expires, omitted if it doesn't
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ParseExportFormat parses a format name, e.g. json or NDJSON
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch format := ExportFormat(strings.ToLower(name)); format {
+	case ExportJSON, ExportNDJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown export format %s, must be json or ndjson", name)
+	}
+}
+
+// StoreNames returns the names of every local store, including stores that haven't been opened since the service started
+func (s *BoltDocService) StoreNames() ([]string, error) {
+	entries, err := os.ReadDir(s.dbDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+
+	storeNames := []string{}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".db" {
+			storeNames = append(storeNames, strings.TrimSuffix(entry.Name(), ".db"))
+		}
+	}
+
+	return storeNames, nil
+}
+
+// Export writes the unexpired keys of the stores to w, every store is exported when no stores are given.
+// Returns the number of keys exported.
+func (s *BoltDocService) Export(w io.Writer, format ExportFormat, storeNames ...string) (int, error) {
+	existingStores, err := s.StoreNames()
+	if err != nil {
+		return 0, err
+	}
+
+	exportStores := existingStores
+
+	if len(storeNames) > 0 {
+		exportStores = make([]string, 0, len(storeNames))
+
+		for _, storeName := range storeNames {
+			// opening a store that doesn't exist would create it
+			if !lo.Contains(existingStores, strings.ToLower(storeName)) {
+				return 0, fmt.Errorf("store %s not found", storeName)
+			}
+
+			exportStores = append(exportStores, strings.ToLower(storeName))
+		}
+	}
+
+	sort.Strings(exportStores)
+
+	// keys are written one at a time, so stores don't need to be held in memory together
+	separator, nextSeparator := "", "\n"
+	if format == ExportJSON {
+		separator, nextSeparator = "[\n", ",\n"
+	}
+
+	count := 0
+
+	for _, storeName := range exportStores {
+		db, err := s.getLocalKVDB(storeName)
+		if err != nil {
+			return count, fmt.Errorf("could not open store %s: %w", storeName, err)
+		}
+
+		docs, err := scanDocs(db, "")
+		if err != nil {
+			return count, fmt.Errorf("could not read store %s: %w", storeName, err)
+		}
+
+		for _, doc := range docs {
+			exportedKey := ExportedKey{
+				Store: storeName,
+				Key:   doc.Id,
+				Value: doc.Value,
+			}
+
+			if !doc.ExpiresAt.IsZero() {
+				expiresAt := doc.ExpiresAt.UTC()
+				exportedKey.ExpiresAt = &expiresAt
+			}
+
+			record, err := json.Marshal(exportedKey)
+			if err != nil {
+				return count, fmt.Errorf("could not export %s/%s: %w", storeName, doc.Id, err)
+			}
+
+			if _, err := io.WriteString(w, separator+string(record)); err != nil {
+				return count, err
+			}
+
+			separator = nextSeparator
+			count++
+		}
+	}
+
+	closing := "\n"
+
+	if format == ExportJSON {
+		closing = "\n]\n"
+		// no keys were written, so the array was never opened
+		if count == 0 {
+			closing = "[]\n"
+		}
+	} else if count == 0 {
+		closing = ""
+	}
+
+	_, err = io.WriteString(w, closing)
+
+	return count, err
+}
+
+// readExport reads the keys of an export in either format, a JSON array is detected by its opening bracket
+func readExport(r io.Reader) ([]ExportedKey, error) {
+	reader := bufio.NewReader(r)
+	decoder := json.NewDecoder(reader)
+
+	// skip leading whitespace to find the first character of the export
+	for {
+		next, err := reader.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return []ExportedKey{}, nil
+			}
+
+			return nil, err
+		}
+
+		if !strings.ContainsRune(" \t\r\n", rune(next[0])) {
+			break
+		}
+
+		_, _ = reader.ReadByte()
+	}
+
+	if next, _ := reader.Peek(1); next[0] == '[' {
+		exportedKeys := []ExportedKey{}
+
+		if err := decoder.Decode(&exportedKeys); err != nil {
+			return nil, fmt.Errorf("invalid JSON export: %w", err)
+		}
+
+		return exportedKeys, nil
+	}
+
+	exportedKeys := []ExportedKey{}
+
+	for record := 1; ; record++ {
+		exportedKey := ExportedKey{}
+
+		err := decoder.Decode(&exportedKey)
+		if errors.Is(err, io.EOF) {
+			return exportedKeys, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid NDJSON export at record %d: %w", record, err)
+		}
+
+		exportedKeys = append(exportedKeys, exportedKey)
+	}
+}
+
+// Import sets the keys of an export in either format, overwriting keys that already exist.
+// Every key is validated and written before any store is committed, so an invalid export or a key that can't be written leaves the stores unchanged.
+// The stores are committed one at a time, if a commit fails the stores committed before it keep their imported keys and are named in the error.
+// Keys that have expired since they were exported are skipped. Returns the number of keys imported.
+func (s *BoltDocService) Import(r io.Reader) (int, error) {
+	exportedKeys, err := readExport(r)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	keysByStore := map[string][]ExportedKey{}
+
+	for i, exportedKey := range exportedKeys {
+		if exportedKey.Store == "" || exportedKey.Key == "" {
+			return 0, fmt.Errorf("record %d is missing a store or key", i+1)
+		}
+
+		if !ValidStoreName(exportedKey.Store) {
+			return 0, fmt.Errorf("record %d has an invalid store name %s", i+1, exportedKey.Store)
+		}
+
+		// the runtime API can only return values that convert to a struct
+		if _, err := structpb.NewStruct(exportedKey.Value); err != nil {
+			return 0, fmt.Errorf("record %d (%s/%s) has an invalid value: %w", i+1, exportedKey.Store, exportedKey.Key, err)
+		}
+
+		if exportedKey.ExpiresAt != nil && !now.Before(*exportedKey.ExpiresAt) {
+			continue
+		}
+
+		// store names are case insensitive, a store can only have one write transaction at a time
+		storeName := strings.ToLower(exportedKey.Store)
+		keysByStore[storeName] = append(keysByStore[storeName], exportedKey)
+	}
+
+	storeNames := lo.Keys(keysByStore)
+	sort.Strings(storeNames)
+
+	// every store is written before any are committed, so a failed import can roll back every store
+	txs := make([]storm.Node, 0, len(storeNames))
+
+	defer func() {
+		for _, tx := range txs {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, storeName := range storeNames {
+		db, err := s.getLocalKVDB(storeName)
+		if err != nil {
+			return 0, fmt.Errorf("could not open store %s: %w", storeName, err)
+		}
+
+		// each store is imported in a single transaction, rather than syncing to disk for every key
+		tx, err := db.Begin(true)
+		if err != nil {
+			return 0, err
+		}
+
+		txs = append(txs, tx)
+
+		for _, exportedKey := range keysByStore[storeName] {
+			// keep the store name as exported, the same as keys set through the runtime API
+			doc := createDoc(&kvstorepb.ValueRef{Store: exportedKey.Store, Key: exportedKey.Key})
+			doc.Value = exportedKey.Value
+
+			if exportedKey.ExpiresAt != nil {
+				doc.ExpiresAt = *exportedKey.ExpiresAt
+			}
+
+			if err := tx.Save(&doc); err != nil {
+				return 0, fmt.Errorf("could not import %s/%s: %w", storeName, exportedKey.Key, err)
+			}
+		}
+	}
+
+	count := 0
+
+	for i, tx := range txs {
+		if err := tx.Commit(); err != nil {
+			if i > 0 {
+				return count, fmt.Errorf("could not import %s, stores %s were already imported: %w", storeNames[i], strings.Join(storeNames[:i], ", "), err)
+			}
+
+			return count, fmt.Errorf("could not import %s: %w", storeNames[i], err)
+		}
+
+		count += len(keysByStore[storeNames[i]])
+	}
+
+	return count, nil
+}
@@ -0,0 +1,176 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyvalue
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	kvstorepb "github.com/nitrictech/nitric/core/pkg/proto/kvstore/v1"
+)
+
+var (
+	ErrKeyNotFound      = errors.New("key not found")
+	ErrKeyExists        = errors.New("key already exists")
+	ErrInvalidStoreName = errors.New("invalid store name")
+	ErrServiceClosed    = errors.New("key value service is closed")
+)
+
+type ListKeysOptions struct {
+	// Only keys starting with the prefix are listed
+	Prefix string
+	// Continues a previous listing, from its NextContinuationToken
+	ContinuationToken string
+	// The maximum number of keys to list, zero lists them all
+	MaxKeys int
+}
+
+type KeyPage struct {
+	Keys                  []*KeyInfo `json:"keys"`
+	NextContinuationToken string     `json:"nextContinuationToken,omitempty"`
+}
+
+type KeyValue struct {
+	KeyInfo
+	Value map[string]interface{} `json:"value"`
+}
+
+func encodeContinuationToken(startAfter string) string {
+	return base64.URLEncoding.EncodeToString([]byte(startAfter))
+}
+
+func decodeContinuationToken(token string) (string, error) {
+	startAfter, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid continuation token: %w", err)
+	}
+
+	return string(startAfter), nil
+}
+
+// ListKeyPage returns the unexpired keys in a store in key order, a page at a time
+func (s *BoltDocService) ListKeyPage(storeName string, opts ListKeysOptions) (*KeyPage, error) {
+	startAfter, err := decodeContinuationToken(opts.ContinuationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := s.getLocalKVDB(storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := scanDocs(db, opts.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	page := &KeyPage{Keys: []*KeyInfo{}}
+
+	for _, doc := range docs {
+		if startAfter != "" && doc.Id <= startAfter {
+			continue
+		}
+
+		if opts.MaxKeys > 0 && len(page.Keys) == opts.MaxKeys {
+			page.NextContinuationToken = encodeContinuationToken(page.Keys[len(page.Keys)-1].Key)
+			break
+		}
+
+		page.Keys = append(page.Keys, newKeyInfo(doc, now))
+	}
+
+	return page, nil
+}
+
+// GetKey returns the value of an unexpired key along with its expiry
+func (s *BoltDocService) GetKey(storeName string, key string) (*KeyValue, error) {
+	db, err := s.getLocalKVDB(storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := createDoc(&kvstorepb.ValueRef{Store: storeName, Key: key})
+	now := time.Now()
+
+	err = db.One(idName, doc.Id, &doc)
+	if err == nil && doc.expired(now) {
+		err = storm.ErrNotFound
+	}
+
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, ErrKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	return &KeyValue{
+		KeyInfo: *newKeyInfo(doc, now),
+		Value:   doc.Value,
+	}, nil
+}
+
+// PutKey sets the value of a key, failing with ErrKeyExists if the key exists and overwrite is false.
+// The value must be convertible to a struct, as values set by the runtime API are.
+func (s *BoltDocService) PutKey(storeName string, key string, value map[string]interface{}, overwrite bool) error {
+	if _, err := structpb.NewStruct(value); err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+
+	db, err := s.getLocalKVDB(storeName)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	doc := createDoc(&kvstorepb.ValueRef{Store: storeName, Key: key})
+
+	if !overwrite {
+		existing := BoltDoc{}
+
+		err := tx.One(idName, doc.Id, &existing)
+		if err == nil && !existing.expired(time.Now()) {
+			return ErrKeyExists
+		}
+
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return err
+		}
+	}
+
+	doc.Value = value
+	doc.ExpiresAt = s.expiresAt(storeName, value)
+
+	if err := tx.Save(&doc); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -24,15 +24,19 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/asdine/storm"
 	"github.com/asdine/storm/q"
+	"github.com/samber/lo"
 	"go.etcd.io/bbolt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/nitrictech/cli/pkg/cloud/env"
+	"github.com/nitrictech/cli/pkg/project/localconfig"
+
 	grpc_errors "github.com/nitrictech/nitric/core/pkg/grpc/errors"
 	kvstorepb "github.com/nitrictech/nitric/core/pkg/proto/kvstore/v1"
 )
@@ -47,6 +51,15 @@ const (
 
 type BoltDocService struct {
 	dbDir string
+
+	// stores holds an open handle to each store for the life of the service, bolt handles are safe for concurrent use
+	storesLock sync.RWMutex
+	stores     map[string]*storm.DB
+	// closed is set once the service is closed, so stores aren't reopened by requests still in flight
+	closed bool
+
+	localConfig localconfig.LocalConfiguration
+	stopSweeper chan struct{}
 }
 
 var _ kvstorepb.KvStoreServer = (*BoltDocService)(nil)
@@ -68,6 +81,8 @@ type BoltDoc struct {
 	PartitionKey string `storm:"index"`
 	SortKey      string `storm:"index"`
 	Value        map[string]interface{}
+	// When the key expires, the zero time if it doesn't
+	ExpiresAt time.Time
 }
 
 func (d BoltDoc) String() string {
@@ -86,11 +101,14 @@ func (s *BoltDocService) GetValue(ctx context.Context, req *kvstorepb.KvStoreGet
 		)
 	}
 
-	defer db.Close()
-
 	doc := createDoc(req.Ref)
 
 	err = db.One(idName, doc.Id, &doc)
+	// expired keys are hidden until they're swept
+	if err == nil && doc.expired(time.Now()) {
+		err = storm.ErrNotFound
+	}
+
 	if err != nil {
 		if errors.Is(err, storm.ErrNotFound) {
 			return nil, newErr(
@@ -133,10 +151,9 @@ func (s *BoltDocService) SetValue(ctx context.Context, req *kvstorepb.KvStoreSet
 		)
 	}
 
-	defer db.Close()
-
 	doc := createDoc(req.Ref)
 	doc.Value = req.Content.AsMap()
+	doc.ExpiresAt = s.expiresAt(req.Ref.Store, doc.Value)
 
 	if err := db.Save(&doc); err != nil {
 		return nil, newErr(
@@ -163,8 +180,6 @@ func (s *BoltDocService) DeleteKey(ctx context.Context, req *kvstorepb.KvStoreDe
 		)
 	}
 
-	defer db.Close()
-
 	doc := createDoc(key)
 
 	err = db.DeleteStruct(&doc)
@@ -187,8 +202,12 @@ func (s *BoltDocService) DeleteKey(ctx context.Context, req *kvstorepb.KvStoreDe
 	return &kvstorepb.KvStoreDeleteKeyResponse{}, nil
 }
 
+type NewBoltServiceOpts struct {
+	LocalConfig localconfig.LocalConfiguration
+}
+
 // New - Create a new dev KV plugin
-func NewBoltService() (*BoltDocService, error) {
+func NewBoltService(opts NewBoltServiceOpts) (*BoltDocService, error) {
 	dbDir := env.LOCAL_DB_DIR.String()
 
 	// Check whether file exists
@@ -201,7 +220,20 @@ func NewBoltService() (*BoltDocService, error) {
 		}
 	}
 
-	return &BoltDocService{dbDir: dbDir}, nil
+	return newBoltService(dbDir, opts.LocalConfig), nil
+}
+
+func newBoltService(dbDir string, localConfig localconfig.LocalConfiguration) *BoltDocService {
+	s := &BoltDocService{
+		dbDir:       dbDir,
+		stores:      map[string]*storm.DB{},
+		localConfig: localConfig,
+		stopSweeper: make(chan struct{}),
+	}
+
+	go s.runSweeper()
+
+	return s
 }
 
 func (s *BoltDocService) ScanKeys(req *kvstorepb.KvStoreScanKeysRequest, stream kvstorepb.KvStore_ScanKeysServer) error {
@@ -225,19 +257,8 @@ func (s *BoltDocService) ScanKeys(req *kvstorepb.KvStoreScanKeysRequest, stream
 		)
 	}
 
-	defer db.Close()
-
-	prefixPattern := "^" + regexp.QuoteMeta(req.GetPrefix())
-
-	var docs []BoltDoc
-
-	err = db.Select(q.Re(idName, prefixPattern)).Find(&docs)
+	docs, err := scanDocs(db, req.GetPrefix())
 	if err != nil {
-		// not found isn't an error, just close the stream and return no results
-		if errors.Is(err, storm.ErrNotFound) {
-			return nil
-		}
-
 		return newErr(
 			codes.Internal,
 			"failed query key/value store",
@@ -260,19 +281,110 @@ func (s *BoltDocService) ScanKeys(req *kvstorepb.KvStoreScanKeysRequest, stream
 	return nil
 }
 
+// scanDocs returns the unexpired documents with keys starting with the prefix, in key order
+func scanDocs(db *storm.DB, prefix string) ([]BoltDoc, error) {
+	prefixPattern := "^" + regexp.QuoteMeta(prefix)
+
+	var docs []BoltDoc
+
+	err := db.Select(q.Re(idName, prefixPattern)).Find(&docs)
+	if err != nil {
+		// not found isn't an error, there are just no results
+		if errors.Is(err, storm.ErrNotFound) {
+			return []BoltDoc{}, nil
+		}
+
+		return nil, err
+	}
+
+	now := time.Now()
+
+	// expired keys are hidden until they're swept
+	return lo.Filter(docs, func(doc BoltDoc, _ int) bool {
+		return !doc.expired(now)
+	}), nil
+}
+
+// ValidStoreName reports whether a store name can be used, store names become file names so they can't be paths
+func ValidStoreName(storeName string) bool {
+	return storeName != "" && !strings.ContainsAny(storeName, `/\`) && !strings.Contains(storeName, "..")
+}
+
+// getLocalKVDB returns the handle to a store, opening it on first use
 func (s *BoltDocService) getLocalKVDB(storeName string) (*storm.DB, error) {
-	dbPath := filepath.Join(s.dbDir, strings.ToLower(storeName)+".db")
+	if !ValidStoreName(storeName) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidStoreName, storeName)
+	}
+
+	storeName = strings.ToLower(storeName)
+
+	s.storesLock.RLock()
+	db, ok := s.stores[storeName]
+	closed := s.closed
+	s.storesLock.RUnlock()
+
+	if closed {
+		return nil, ErrServiceClosed
+	}
+
+	if ok {
+		return db, nil
+	}
+
+	s.storesLock.Lock()
+	defer s.storesLock.Unlock()
+
+	// the service may have been closed, or the store opened, while waiting for the lock
+	if s.closed {
+		return nil, ErrServiceClosed
+	}
+
+	if db, ok := s.stores[storeName]; ok {
+		return db, nil
+	}
+
+	dbPath := filepath.Join(s.dbDir, storeName+".db")
 
 	options := storm.BoltOptions(0o600, &bbolt.Options{Timeout: 1 * time.Second})
 
 	db, err := storm.Open(dbPath, options)
 	if err != nil {
+		if errors.Is(err, bbolt.ErrTimeout) {
+			return nil, fmt.Errorf("store %s is in use by another process, such as a running local cloud: %w", storeName, err)
+		}
+
 		return nil, err
 	}
 
+	s.stores[storeName] = db
+
 	return db, nil
 }
 
+// Close the handles to every open store
+func (s *BoltDocService) Close() error {
+	s.storesLock.Lock()
+	defer s.storesLock.Unlock()
+
+	select {
+	case <-s.stopSweeper:
+	default:
+		close(s.stopSweeper)
+	}
+
+	s.closed = true
+
+	errs := []error{}
+
+	for storeName, db := range s.stores {
+		errs = append(errs, db.Close())
+
+		delete(s.stores, storeName)
+	}
+
+	return errors.Join(errs...)
+}
+
 func createDoc(key *kvstorepb.ValueRef) BoltDoc {
 	return BoltDoc{
 		Id:           key.Key,
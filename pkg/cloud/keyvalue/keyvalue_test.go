@@ -0,0 +1,525 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyvalue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/google/go-cmp/cmp"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nitrictech/cli/pkg/project/localconfig"
+	kvstorepb "github.com/nitrictech/nitric/core/pkg/proto/kvstore/v1"
+)
+
+const benchmarkKeys = 1000
+
+func newBenchmarkService(b *testing.B, stores []string) *BoltDocService {
+	b.Helper()
+
+	s := newBoltService(b.TempDir(), localconfig.LocalConfiguration{})
+
+	b.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			b.Error(err)
+		}
+	})
+
+	value := map[string]interface{}{"name": "benchmark", "count": 1}
+
+	// seed each store in a single transaction, rather than syncing to disk for every key
+	for _, store := range stores {
+		db, err := s.getLocalKVDB(store)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		tx, err := db.Begin(true)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for i := 0; i < benchmarkKeys; i++ {
+			doc := createDoc(&kvstorepb.ValueRef{Store: store, Key: fmt.Sprintf("key-%d", i)})
+			doc.Value = value
+
+			if err := tx.Save(&doc); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return s
+}
+
+// BenchmarkParallel measures throughput with many goroutines sharing the store handles, as several services would.
+// Run with -cpu to vary the parallelism, e.g. go test -bench Parallel -cpu 1,4,16 ./pkg/cloud/keyvalue
+func BenchmarkParallel(b *testing.B) {
+	stores := []string{"orders", "customers", "products"}
+
+	content, err := structpb.NewStruct(map[string]interface{}{"name": "benchmark", "count": 2})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, writePercent := range []int{0, 10, 50} {
+		b.Run(fmt.Sprintf("writes=%d%%", writePercent), func(b *testing.B) {
+			s := newBenchmarkService(b, stores)
+
+			var counter atomic.Int64
+
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					n := counter.Add(1)
+					ref := &kvstorepb.ValueRef{
+						Store: stores[n%int64(len(stores))],
+						Key:   fmt.Sprintf("key-%d", n%benchmarkKeys),
+					}
+
+					if n%100 < int64(writePercent) {
+						_, err := s.SetValue(context.Background(), &kvstorepb.KvStoreSetValueRequest{Ref: ref, Content: content})
+						if err != nil {
+							b.Error(err)
+						}
+
+						continue
+					}
+
+					_, err := s.GetValue(context.Background(), &kvstorepb.KvStoreGetValueRequest{Ref: ref})
+					if err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestValidStoreName(t *testing.T) {
+	for i, tt := range []struct {
+		storeName string
+		expected  bool
+	}{
+		{storeName: "orders", expected: true},
+		{storeName: "order-items", expected: true},
+		{storeName: "Orders.v2", expected: true},
+		{storeName: "", expected: false},
+		{storeName: "..", expected: false},
+		{storeName: "../orders", expected: false},
+		{storeName: "orders/..", expected: false},
+		{storeName: "orders/items", expected: false},
+		{storeName: `orders\items`, expected: false},
+		{storeName: `..\orders`, expected: false},
+	} {
+		t.Run(fmt.Sprintf("test ValidStoreName: %d", i), func(t *testing.T) {
+			if actual := ValidStoreName(tt.storeName); actual != tt.expected {
+				t.Fatalf("expected ValidStoreName(%q) to be %t", tt.storeName, tt.expected)
+			}
+
+			if tt.expected {
+				return
+			}
+
+			s := newBoltService(t.TempDir(), localconfig.LocalConfiguration{})
+
+			// invalid stores are rejected before they are opened
+			_, err := s.GetKey(tt.storeName, "key")
+			if !errors.Is(err, ErrInvalidStoreName) {
+				t.Errorf("expected GetKey to fail with ErrInvalidStoreName, got %v", err)
+			}
+
+			err = s.PutKey(tt.storeName, "key", map[string]interface{}{"name": "test"}, true)
+			if !errors.Is(err, ErrInvalidStoreName) {
+				t.Errorf("expected PutKey to fail with ErrInvalidStoreName, got %v", err)
+			}
+
+			_, err = s.ListKeyPage(tt.storeName, ListKeysOptions{})
+			if !errors.Is(err, ErrInvalidStoreName) {
+				t.Errorf("expected ListKeyPage to fail with ErrInvalidStoreName, got %v", err)
+			}
+		})
+	}
+}
+
+func newTestService(t *testing.T, localConfig localconfig.LocalConfiguration) *BoltDocService {
+	t.Helper()
+
+	s := newBoltService(t.TempDir(), localConfig)
+
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return s
+}
+
+// scanKeysStream collects the keys sent by ScanKeys
+type scanKeysStream struct {
+	grpc.ServerStream
+	keys []string
+}
+
+func (s *scanKeysStream) Send(resp *kvstorepb.KvStoreScanKeysResponse) error {
+	s.keys = append(s.keys, resp.Key)
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	s := newTestService(t, localconfig.LocalConfiguration{})
+
+	if err := s.PutKey("orders", "order-1", map[string]interface{}{"item": "book"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// stores shouldn't be reopened once the service is closed
+	for _, storeName := range []string{"orders", "customers"} {
+		_, err := s.GetKey(storeName, "order-1")
+		if !errors.Is(err, ErrServiceClosed) {
+			t.Errorf("expected reading from %s after closing to fail with %v, got %v", storeName, ErrServiceClosed, err)
+		}
+	}
+
+	if len(s.stores) > 0 {
+		t.Errorf("expected no open stores, got %d", len(s.stores))
+	}
+}
+
+func TestTTLAttribute(t *testing.T) {
+	const store = "sessions"
+
+	s := newTestService(t, localconfig.LocalConfiguration{
+		KeyValue: map[string]localconfig.LocalKeyValueConfiguration{
+			store: {TTLAttribute: "expiry"},
+		},
+	})
+
+	now := time.Now()
+
+	for key, value := range map[string]map[string]interface{}{
+		"expired": {"name": "expired", "expiry": now.Add(-time.Hour).Unix()},
+		"live":    {"name": "live", "expiry": now.Add(time.Hour).Unix()},
+		// the attribute is ignored when it isn't a number
+		"forever": {"name": "forever", "expiry": "never"},
+	} {
+		content, err := structpb.NewStruct(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.SetValue(context.Background(), &kvstorepb.KvStoreSetValueRequest{
+			Ref:     &kvstorepb.ValueRef{Store: store, Key: key},
+			Content: content,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := s.GetValue(context.Background(), &kvstorepb.KvStoreGetValueRequest{Ref: &kvstorepb.ValueRef{Store: store, Key: "expired"}})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected the expired key to be not found, got %v", err)
+	}
+
+	for _, key := range []string{"live", "forever"} {
+		_, err := s.GetValue(context.Background(), &kvstorepb.KvStoreGetValueRequest{Ref: &kvstorepb.ValueRef{Store: store, Key: key}})
+		if err != nil {
+			t.Errorf("expected key %s to be found, got %v", key, err)
+		}
+	}
+
+	stream := &scanKeysStream{}
+
+	err = s.ScanKeys(&kvstorepb.KvStoreScanKeysRequest{Store: &kvstorepb.Store{Name: store}}, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"forever", "live"}; !cmp.Equal(expected, stream.keys) {
+		t.Error(cmp.Diff(expected, stream.keys))
+	}
+
+	db, err := s.getLocalKVDB(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the expired key is hidden, but remains in the store until it is swept
+	doc := BoltDoc{}
+	if err := db.One(idName, "expired", &doc); err != nil {
+		t.Fatalf("expected the expired key to be stored until it is swept, got %v", err)
+	}
+
+	s.sweep()
+
+	if err := db.One(idName, "expired", &doc); !errors.Is(err, storm.ErrNotFound) {
+		t.Errorf("expected the expired key to be removed by the sweep, got %v", err)
+	}
+
+	for _, key := range []string{"live", "forever"} {
+		if err := db.One(idName, key, &doc); err != nil {
+			t.Errorf("expected key %s to be kept by the sweep, got %v", key, err)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	now := time.Now()
+
+	source := newTestService(t, localconfig.LocalConfiguration{})
+
+	for _, ref := range []struct {
+		store     string
+		key       string
+		value     map[string]interface{}
+		expiresAt time.Time
+	}{
+		{store: "orders", key: "order-1", value: map[string]interface{}{"item": "book", "quantity": 2.0}},
+		{store: "orders", key: "order-2", value: map[string]interface{}{"item": "pen", "tags": []interface{}{"blue", "red"}, "gift": map[string]interface{}{"wrapped": true}}, expiresAt: now.Add(time.Hour)},
+		// expired keys are hidden until they're swept, so are not exported
+		{store: "orders", key: "order-expired", value: map[string]interface{}{"item": "lamp"}, expiresAt: now.Add(-time.Hour)},
+		{store: "customers", key: "customer-1", value: map[string]interface{}{"name": "Ada"}},
+	} {
+		db, err := source.getLocalKVDB(ref.store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doc := createDoc(&kvstorepb.ValueRef{Store: ref.store, Key: ref.key})
+		doc.Value = ref.value
+		doc.ExpiresAt = ref.expiresAt
+
+		if err := db.Save(&doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, format := range []ExportFormat{ExportJSON, ExportNDJSON} {
+		t.Run(fmt.Sprintf("test export and import: %s", format), func(t *testing.T) {
+			var export bytes.Buffer
+
+			exported, err := source.Export(&export, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if exported != 3 {
+				t.Errorf("expected 3 keys to be exported, got %d", exported)
+			}
+
+			if strings.Contains(export.String(), "order-expired") {
+				t.Errorf("expected the expired key not to be exported, got %s", export.String())
+			}
+
+			destination := newTestService(t, localconfig.LocalConfiguration{})
+
+			imported, err := destination.Import(bytes.NewReader(export.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if imported != exported {
+				t.Errorf("expected %d keys to be imported, got %d", exported, imported)
+			}
+
+			// exporting the imported keys should export the same keys, values and expiry times
+			var reexport bytes.Buffer
+
+			_, err = destination.Export(&reexport, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(export.String(), reexport.String()) {
+				t.Error(cmp.Diff(export.String(), reexport.String()))
+			}
+		})
+	}
+}
+
+func TestImportExpiredKeys(t *testing.T) {
+	s := newTestService(t, localconfig.LocalConfiguration{})
+
+	expired := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	live := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	imported, err := s.Import(strings.NewReader(
+		`{"store": "orders", "key": "expired", "value": {"item": "book"}, "expiresAt": "` + expired + `"}` + "\n" +
+			`{"store": "orders", "key": "live", "value": {"item": "pen"}, "expiresAt": "` + live + `"}` + "\n",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imported != 1 {
+		t.Errorf("expected 1 key to be imported, got %d", imported)
+	}
+
+	if _, err := s.GetKey("orders", "expired"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected the key that expired since it was exported to be skipped, got %v", err)
+	}
+
+	value, err := s.GetKey("orders", "live")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value.ExpiresAt == nil || value.ExpiresAt.Format(time.RFC3339) != live {
+		t.Errorf("expected the imported key to expire at %s, got %v", live, value.ExpiresAt)
+	}
+}
+
+func TestImportStoreName(t *testing.T) {
+	s := newTestService(t, localconfig.LocalConfiguration{})
+
+	_, err := s.SetValue(context.TODO(), &kvstorepb.KvStoreSetValueRequest{
+		Ref:     &kvstorepb.ValueRef{Store: "Sessions", Key: "set"},
+		Content: &structpb.Struct{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Import(strings.NewReader(`[{"store": "Sessions", "key": "imported", "value": {}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := s.getLocalKVDB("Sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// imported keys should keep the store name as written, the same as keys set through the runtime API
+	for _, key := range []string{"set", "imported"} {
+		doc := BoltDoc{}
+
+		if err := db.One("Id", key, &doc); err != nil {
+			t.Fatal(err)
+		}
+
+		if doc.SortKey != "Sessions" {
+			t.Errorf("expected key %s to be in store Sessions, got %s", key, doc.SortKey)
+		}
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	for i, tt := range []struct {
+		export string
+	}{
+		{export: `[{"store": "orders", "key": "order-1", "value": {"item": "pen"}}, {"key": "order-2", "value": {}}]`},
+		{export: `[{"store": "orders", "key": "order-1", "value": {"item": "pen"}}, {"store": "orders", "value": {}}]`},
+		{export: `[{"store": "orders", "key": "order-1", "value": {"item": "pen"}}, {"store": "../orders", "key": "order-2", "value": {}}]`},
+		{export: `[{"store": "customers", "key": "customer-1", "value": {"name": "Ada"}}, {"store": "orders", "key": "order-1", "value": 5}]`},
+		{export: `{"store": "customers", "key": "customer-1", "value": {"name": "Ada"}}` + "\n" + `{"store": "orders", "key": "order-1", "value": "pen"}`},
+		{export: `{"store": "customers", "key": "customer-1", "value": {"name": "Ada"}}` + "\n" + `{"store": "orders", "key": "order-1", "value": {"item": "pen"}`},
+		{export: `[{"store": "customers", "key": "customer-1", "value": {"name": "Ada"}}`},
+	} {
+		t.Run(fmt.Sprintf("test Import invalid: %d", i), func(t *testing.T) {
+			s := newTestService(t, localconfig.LocalConfiguration{})
+
+			original := map[string]interface{}{"item": "book"}
+
+			if err := s.PutKey("orders", "order-1", original, true); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := s.Import(strings.NewReader(tt.export))
+			if err == nil {
+				t.Fatalf("expected importing %s to fail", tt.export)
+			}
+
+			// an invalid export leaves the stores unchanged
+			value, err := s.GetKey("orders", "order-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(original, value.Value) {
+				t.Error(cmp.Diff(original, value.Value))
+			}
+
+			storeNames, err := s.StoreNames()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := []string{"orders"}; !cmp.Equal(expected, storeNames) {
+				t.Error(cmp.Diff(expected, storeNames))
+			}
+		})
+	}
+}
+
+func TestImportRollback(t *testing.T) {
+	s := newTestService(t, localconfig.LocalConfiguration{})
+
+	original := map[string]interface{}{"item": "book"}
+
+	if err := s.PutKey("orders", "order-1", original, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// a store in use by another process can't be opened, so is the last store imported
+	locked, err := bbolt.Open(filepath.Join(s.dbDir, "zebras.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer locked.Close()
+
+	_, err = s.Import(strings.NewReader(
+		`[{"store": "orders", "key": "order-1", "value": {"item": "pen"}}, {"store": "zebras", "key": "zebra-1", "value": {"name": "Zed"}}]`,
+	))
+	if err == nil {
+		t.Fatal("expected importing into a store in use to fail")
+	}
+
+	// the keys written to stores before the failure are rolled back
+	value, err := s.GetKey("orders", "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cmp.Equal(original, value.Value) {
+		t.Error(cmp.Diff(original, value.Value))
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyvalue
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+
+	"github.com/nitrictech/cli/pkg/project/localconfig"
+	"github.com/nitrictech/nitric/core/pkg/logger"
+)
+
+const (
+	expiresAtName = "ExpiresAt"
+	// how often expired keys are removed from the open stores, expired keys are hidden as soon as they expire
+	sweepInterval = 30 * time.Second
+)
+
+// storeConfig returns the local configuration of a store, store names are case insensitive
+func (s *BoltDocService) storeConfig(storeName string) localconfig.LocalKeyValueConfiguration {
+	for name, config := range s.localConfig.KeyValue {
+		if strings.EqualFold(name, storeName) {
+			return config
+		}
+	}
+
+	return localconfig.LocalKeyValueConfiguration{}
+}
+
+// expiresAt returns when a value set now expires, the zero time if it doesn't.
+// The value's ttl attribute takes precedence over the default ttl of the store.
+func (s *BoltDocService) expiresAt(storeName string, value map[string]interface{}) time.Time {
+	config := s.storeConfig(storeName)
+
+	if config.TTLAttribute != "" {
+		// like DynamoDB, the attribute is the expiry in unix epoch seconds and is ignored if it isn't a number
+		if expiry, ok := value[config.TTLAttribute].(float64); ok {
+			return time.Unix(int64(expiry), 0)
+		}
+	}
+
+	if config.TTL > 0 {
+		return time.Now().Add(time.Duration(config.TTL) * time.Second)
+	}
+
+	return time.Time{}
+}
+
+func (d BoltDoc) expired(now time.Time) bool {
+	return !d.ExpiresAt.IsZero() && !now.Before(d.ExpiresAt)
+}
+
+// expiredMatcher matches documents with an expiry at or before a time
+type expiredMatcher struct {
+	now time.Time
+}
+
+func (m expiredMatcher) MatchField(v interface{}) (bool, error) {
+	expiresAt, ok := v.(time.Time)
+	if !ok {
+		return false, nil
+	}
+
+	return BoltDoc{ExpiresAt: expiresAt}.expired(m.now), nil
+}
+
+// sweep deletes the expired keys from the open stores
+func (s *BoltDocService) sweep() {
+	// the read lock keeps the stores open while they're swept
+	s.storesLock.RLock()
+	defer s.storesLock.RUnlock()
+
+	now := time.Now()
+
+	for storeName, db := range s.stores {
+		err := db.Select(q.NewFieldMatcher(expiresAtName, expiredMatcher{now: now})).Delete(&BoltDoc{})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			logger.Errorf("Error removing expired keys from %s: %s", storeName, err.Error())
+		}
+	}
+}
+
+func (s *BoltDocService) runSweeper() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.stopSweeper:
+			return
+		}
+	}
+}
+
+type KeyInfo struct {
+	Key string `json:"key"`
+	// When the key expires, nil if it doesn't
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// The remaining time to live in seconds, nil if the key doesn't expire
+	TTL *int64 `json:"ttl,omitempty"`
+}
+
+func newKeyInfo(doc BoltDoc, now time.Time) *KeyInfo {
+	keyInfo := &KeyInfo{Key: doc.Id}
+
+	if !doc.ExpiresAt.IsZero() {
+		expiresAt := doc.ExpiresAt
+		ttl := int64(expiresAt.Sub(now).Round(time.Second).Seconds())
+
+		keyInfo.ExpiresAt = &expiresAt
+		keyInfo.TTL = &ttl
+	}
+
+	return keyInfo
+}
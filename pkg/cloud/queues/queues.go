@@ -18,64 +18,456 @@ package queues
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/asaskevich/EventBus"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
 	"github.com/google/uuid"
-	"github.com/samber/lo"
+	"go.etcd.io/bbolt"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/nitrictech/cli/pkg/cloud/env"
+	"github.com/nitrictech/cli/pkg/grpcx"
+	"github.com/nitrictech/cli/pkg/project/localconfig"
+	"github.com/nitrictech/cli/pkg/system"
 	grpc_errors "github.com/nitrictech/nitric/core/pkg/grpc/errors"
+	"github.com/nitrictech/nitric/core/pkg/logger"
 	queuespb "github.com/nitrictech/nitric/core/pkg/proto/queues/v1"
 )
 
-type (
-	queueName   = string
-	serviceName = string
+type queueName = string
+
+// QueueState - a summary of the messages in a local queue
+type QueueState struct {
+	// Depth is the number of messages available to be dequeued
+	Depth int `json:"depth"`
+	// InFlight is the number of messages currently leased by a consumer
+	InFlight int `json:"inFlight"`
+}
+
+type State = map[queueName]QueueState
+
+type QueueAction string
+
+const (
+	QueueAction_Enqueue      QueueAction = "enqueue"
+	QueueAction_Dequeue      QueueAction = "dequeue"
+	QueueAction_Complete     QueueAction = "complete"
+	QueueAction_LeaseExpired QueueAction = "lease-expired"
+	QueueAction_ExtendLease  QueueAction = "extend-lease"
+	QueueAction_DeadLetter   QueueAction = "dead-letter"
+	QueueAction_Purge        QueueAction = "purge"
 )
 
-type State = map[queueName]map[serviceName]int
+type ActionState struct {
+	QueueName   string
+	Action      QueueAction
+	Payload     string
+	LeaseId     string
+	ServiceName string
+}
 
 type Lease struct {
 	Id     string
 	Expiry time.Time
+	// ServiceName is the name of the service that dequeued the message
+	ServiceName string
 }
 
+// QueueItem - a message stored in a local queue, persisted so queue contents survive restarts
 type QueueItem struct {
-	lease   *Lease
-	message *queuespb.QueueMessage
+	// Id is auto-incremented, preserving the order messages were enqueued in
+	Id        int    `storm:"id,increment"`
+	QueueName string `storm:"index"`
+	Lease     *Lease
+	// ReceiveCount is the number of times the message has been leased from its current queue
+	ReceiveCount int
+	// Message is the protobuf encoded queuespb.QueueMessage
+	Message []byte
+}
+
+func (i *QueueItem) isLeased(now time.Time) bool {
+	return i.Lease != nil && i.Lease.Expiry.After(now)
+}
+
+func (i *QueueItem) queueMessage() (*queuespb.QueueMessage, error) {
+	msg := &queuespb.QueueMessage{}
+
+	err := proto.Unmarshal(i.Message, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
 }
 
+// payload returns the JSON payload of the message, or an empty string if it can't be read
+func (i *QueueItem) payload() string {
+	msg, err := i.queueMessage()
+	if err != nil {
+		return ""
+	}
+
+	json, err := msg.GetStructPayload().MarshalJSON()
+	if err != nil {
+		return ""
+	}
+
+	return string(json)
+}
+
+// QueueMessageInfo - a message in a local queue, as viewed from the dashboard
+type QueueMessageInfo struct {
+	Id           int        `json:"id"`
+	Payload      string     `json:"payload"`
+	ReceiveCount int        `json:"receiveCount"`
+	LeaseId      string     `json:"leaseId,omitempty"`
+	LeaseExpiry  *time.Time `json:"leaseExpiry,omitempty"`
+}
+
+// queueTx - a write transaction against the queue store, which records the actions performed so they can be published once committed
+type queueTx struct {
+	storm.Node
+	// serviceName is the name of the service performing the operation, empty for dashboard and background operations
+	serviceName string
+	actions     []ActionState
+}
+
+func (tx *queueTx) recordAction(item *QueueItem, action QueueAction) {
+	actionState := ActionState{
+		QueueName:   item.QueueName,
+		Action:      action,
+		Payload:     item.payload(),
+		ServiceName: tx.serviceName,
+	}
+
+	if item.Lease != nil {
+		actionState.LeaseId = item.Lease.Id
+
+		// lease expiries and extensions are attributed to the service that held the lease
+		if action == QueueAction_LeaseExpired || action == QueueAction_ExtendLease {
+			actionState.ServiceName = item.Lease.ServiceName
+		}
+	}
+
+	tx.actions = append(tx.actions, actionState)
+}
+
+const queuesDbName = "queues.db"
+
 type LocalQueuesService struct {
 	queueLock sync.Mutex
 
-	queues map[queueName][]*QueueItem
+	db     *storm.DB
+	config map[queueName]localconfig.LocalQueueConfiguration
+	stop   chan bool
+
+	// actions committed by the last operation, published once the queue lock has been released
+	pendingActions []ActionState
+
+	bus EventBus.Bus
 }
 
 var (
 	_                        queuespb.QueuesServer = (*LocalQueuesService)(nil)
 	defaultVisibilityTimeout                       = 30 * time.Second
+	leaseSweepInterval                             = 1 * time.Second
 )
 
-func (l *LocalQueuesService) ensureQueue(queueName string) {
-	if _, ok := l.queues[queueName]; !ok {
-		l.queues[queueName] = []*QueueItem{}
+const localQueuesTopic = "local_queues"
+
+const localQueuesActionTopic = "local_queues_action"
+
+func (l *LocalQueuesService) SubscribeToState(fn func(State)) {
+	// ignore the error, it's only returned if the fn param isn't a function
+	_ = l.bus.Subscribe(localQueuesTopic, fn)
+}
+
+func (l *LocalQueuesService) SubscribeToAction(subscription func(ActionState)) {
+	// ignore the error, it's only returned if the fn param isn't a function
+	_ = l.bus.Subscribe(localQueuesActionTopic, subscription)
+}
+
+// begin starts a new write transaction against the queue store on behalf of the service calling with ctx
+func (l *LocalQueuesService) begin(ctx context.Context) (*queueTx, error) {
+	tx, err := l.db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// requests from the dashboard or background tasks won't include a service name
+	serviceName, _ := grpcx.GetServiceNameFromIncomingContext(ctx)
+
+	return &queueTx{Node: tx, serviceName: serviceName}, nil
+}
+
+// commit commits the transaction and queues its actions to be published, must be called while holding the queue lock
+func (l *LocalQueuesService) commit(tx *queueTx) error {
+	err := tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	l.pendingActions = append(l.pendingActions, tx.actions...)
+
+	return nil
+}
+
+// publishPendingActions publishes the actions committed by the last operation along with the new queue state.
+// Must be called without holding the queue lock, so subscribers are free to query the queues.
+func (l *LocalQueuesService) publishPendingActions() {
+	l.queueLock.Lock()
+	actions := l.pendingActions
+	l.pendingActions = nil
+	l.queueLock.Unlock()
+
+	if len(actions) == 0 {
+		return
+	}
+
+	for _, action := range actions {
+		l.bus.Publish(localQueuesActionTopic, action)
+	}
+
+	state, err := l.GetState()
+	if err != nil {
+		logger.Errorf("error reading local queue state: %s", err.Error())
+		return
+	}
+
+	l.bus.Publish(localQueuesTopic, state)
+}
+
+// GetState returns a summary of all queues containing messages
+func (l *LocalQueuesService) GetState() (State, error) {
+	l.queueLock.Lock()
+	defer l.queueLock.Unlock()
+
+	items := []QueueItem{}
+
+	err := l.db.All(&items)
+	if err != nil {
+		return nil, err
+	}
+
+	state := State{}
+	now := time.Now()
+
+	for _, item := range items {
+		queueState := state[item.QueueName]
+
+		if item.isLeased(now) {
+			queueState.InFlight++
+		} else {
+			queueState.Depth++
+		}
+
+		state[item.QueueName] = queueState
+	}
+
+	return state, nil
+}
+
+// visibilityTimeout returns the configured visibility timeout for a queue, or the default if none is configured
+func (l *LocalQueuesService) visibilityTimeout(queueName string) time.Duration {
+	if config, ok := l.config[queueName]; ok && config.VisibilityTimeout > 0 {
+		return time.Duration(config.VisibilityTimeout) * time.Second
+	}
+
+	return defaultVisibilityTimeout
+}
+
+// getQueueItems returns all items in a queue, in the order they were enqueued
+func (l *LocalQueuesService) getQueueItems(queueName string) ([]QueueItem, error) {
+	items := []QueueItem{}
+
+	err := l.db.Select(q.Eq("QueueName", queueName)).OrderBy("Id").Find(&items)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// GetDeadLetterQueues returns a map of queue names to their configured dead-letter queue
+func (l *LocalQueuesService) GetDeadLetterQueues() map[string]string {
+	deadLetterQueues := map[string]string{}
+
+	for name, config := range l.config {
+		if config.DeadLetterQueue != "" && config.MaxReceiveCount > 0 {
+			deadLetterQueues[name] = config.DeadLetterQueue
+		}
+	}
+
+	return deadLetterQueues
+}
+
+// isExhausted returns true if the item has been received the maximum number of times allowed by its queue
+func (l *LocalQueuesService) isExhausted(item *QueueItem) bool {
+	config, ok := l.config[item.QueueName]
+	if !ok || config.DeadLetterQueue == "" || config.MaxReceiveCount < 1 {
+		return false
+	}
+
+	return item.ReceiveCount >= config.MaxReceiveCount
+}
+
+// moveToDeadLetterQueue moves an item to the dead-letter queue configured for its current queue
+func (l *LocalQueuesService) moveToDeadLetterQueue(tx *queueTx, item *QueueItem) error {
+	sourceQueue := item.QueueName
+	deadLetterQueue := l.config[sourceQueue].DeadLetterQueue
+
+	tx.recordAction(item, QueueAction_DeadLetter)
+
+	item.QueueName = deadLetterQueue
+	item.Lease = nil
+	item.ReceiveCount = 0
+
+	err := tx.Save(item)
+	if err != nil {
+		return err
+	}
+
+	system.Logf("message exceeded max receive count of %d for queue '%s', moved to dead-letter queue '%s'", l.config[sourceQueue].MaxReceiveCount, sourceQueue, deadLetterQueue)
+
+	return nil
+}
+
+// expireLease releases an expired lease, returning the item to its queue or moving it to the dead-letter queue if it has been received too many times.
+// Returns true if the item was moved to the dead-letter queue.
+func (l *LocalQueuesService) expireLease(tx *queueTx, item *QueueItem) (bool, error) {
+	tx.recordAction(item, QueueAction_LeaseExpired)
+
+	if l.isExhausted(item) {
+		system.Logf("lease %s on queue '%s' expired at %s, message moved to dead-letter queue", item.Lease.Id, item.QueueName, item.Lease.Expiry.Format(time.RFC3339))
+
+		return true, l.moveToDeadLetterQueue(tx, item)
+	}
+
+	system.Logf("lease %s on queue '%s' expired at %s, message returned to queue", item.Lease.Id, item.QueueName, item.Lease.Expiry.Format(time.RFC3339))
+
+	item.Lease = nil
+
+	return false, tx.Save(item)
+}
+
+// sweepExpiredLeases releases all expired leases across all queues
+func (l *LocalQueuesService) sweepExpiredLeases() error {
+	defer l.publishPendingActions()
+
+	l.queueLock.Lock()
+	defer l.queueLock.Unlock()
+
+	items := []QueueItem{}
+
+	err := l.db.All(&items)
+	if err != nil {
+		return err
+	}
+
+	tx, err := l.begin(context.Background())
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+
+	for _, item := range items {
+		if item.Lease == nil || item.isLeased(now) {
+			continue
+		}
+
+		_, err := l.expireLease(tx, &item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return l.commit(tx)
+}
+
+func (l *LocalQueuesService) runLeaseSweeper() {
+	ticker := time.NewTicker(leaseSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := l.sweepExpiredLeases()
+			if err != nil {
+				logger.Errorf("error releasing expired queue leases: %s", err.Error())
+			}
+		case <-l.stop:
+			return
+		}
 	}
 }
 
 // Send messages to a queue
 func (l *LocalQueuesService) Enqueue(ctx context.Context, req *queuespb.QueueEnqueueRequest) (*queuespb.QueueEnqueueResponse, error) {
+	newErr := grpc_errors.ErrorsWithScope("DevQueuesService.Enqueue")
+
+	defer l.publishPendingActions()
+
 	l.queueLock.Lock()
 	defer l.queueLock.Unlock()
-	l.ensureQueue(req.QueueName)
+
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to open queue store",
+			err,
+		)
+	}
+
+	defer func() { _ = tx.Rollback() }()
 
 	// queue the payloads
-	l.queues[req.QueueName] = append(l.queues[req.QueueName], lo.Map(req.Messages, func(task *queuespb.QueueMessage, idx int) *QueueItem {
-		return &QueueItem{
-			message: task,
+	for _, message := range req.Messages {
+		msgBytes, err := proto.Marshal(message)
+		if err != nil {
+			return nil, newErr(
+				codes.InvalidArgument,
+				"failed to serialize message",
+				err,
+			)
+		}
+
+		item := &QueueItem{
+			QueueName: req.QueueName,
+			Message:   msgBytes,
+		}
+
+		err = tx.Save(item)
+		if err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"failed to store message",
+				err,
+			)
 		}
-	})...)
+
+		tx.recordAction(item, QueueAction_Enqueue)
+	}
+
+	err = l.commit(tx)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to store messages",
+			err,
+		)
+	}
 
 	return &queuespb.QueueEnqueueResponse{}, nil
 }
@@ -84,9 +476,10 @@ func (l *LocalQueuesService) Enqueue(ctx context.Context, req *queuespb.QueueEnq
 func (l *LocalQueuesService) Dequeue(ctx context.Context, req *queuespb.QueueDequeueRequest) (*queuespb.QueueDequeueResponse, error) {
 	newErr := grpc_errors.ErrorsWithScope("DevQueuesService.Dequeue")
 
+	defer l.publishPendingActions()
+
 	l.queueLock.Lock()
 	defer l.queueLock.Unlock()
-	l.ensureQueue(req.QueueName)
 
 	if req.Depth < 1 {
 		return nil, newErr(
@@ -102,25 +495,85 @@ func (l *LocalQueuesService) Dequeue(ctx context.Context, req *queuespb.QueueDeq
 		)
 	}
 
+	items, err := l.getQueueItems(req.QueueName)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to read queue",
+			err,
+		)
+	}
+
 	resp := &queuespb.QueueDequeueResponse{
 		Messages: []*queuespb.DequeuedMessage{},
 	}
 
-	// remove the leased tasks from the queue
-	for _, queueItem := range l.queues[req.QueueName] {
-		if queueItem.lease != nil && queueItem.lease.Expiry.After(time.Now()) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to open queue store",
+			err,
+		)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+
+	// lease the available tasks in the queue
+	for _, queueItem := range items {
+		if queueItem.isLeased(now) {
 			// the task is still leased, so it's not available
 			continue
 		}
 
-		queueItem.lease = &Lease{
-			Id:     uuid.New().String(),
-			Expiry: time.Now().Add(defaultVisibilityTimeout),
+		// the lease has expired but hasn't been swept yet
+		if queueItem.Lease != nil {
+			deadLettered, err := l.expireLease(tx, &queueItem)
+			if err != nil {
+				return nil, newErr(
+					codes.Internal,
+					"failed to release expired lease",
+					err,
+				)
+			}
+
+			if deadLettered {
+				continue
+			}
+		}
+
+		message, err := queueItem.queueMessage()
+		if err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"failed to deserialize message",
+				err,
+			)
+		}
+
+		queueItem.Lease = &Lease{
+			Id:          uuid.New().String(),
+			Expiry:      now.Add(l.visibilityTimeout(req.QueueName)),
+			ServiceName: tx.serviceName,
 		}
+		queueItem.ReceiveCount++
+
+		err = tx.Save(&queueItem)
+		if err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"failed to lease message",
+				err,
+			)
+		}
+
+		tx.recordAction(&queueItem, QueueAction_Dequeue)
 
 		resp.Messages = append(resp.Messages, &queuespb.DequeuedMessage{
-			LeaseId: queueItem.lease.Id,
-			Message: queueItem.message,
+			LeaseId: queueItem.Lease.Id,
+			Message: message,
 		})
 
 		if len(resp.Messages) >= int(req.Depth) {
@@ -128,6 +581,15 @@ func (l *LocalQueuesService) Dequeue(ctx context.Context, req *queuespb.QueueDeq
 		}
 	}
 
+	err = l.commit(tx)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to lease messages",
+			err,
+		)
+	}
+
 	return resp, nil
 }
 
@@ -135,24 +597,64 @@ func (l *LocalQueuesService) Dequeue(ctx context.Context, req *queuespb.QueueDeq
 func (l *LocalQueuesService) Complete(ctx context.Context, req *queuespb.QueueCompleteRequest) (*queuespb.QueueCompleteResponse, error) {
 	newErr := grpc_errors.ErrorsWithScope("DevQueuesService.Complete")
 
+	defer l.publishPendingActions()
+
 	l.queueLock.Lock()
 	defer l.queueLock.Unlock()
-	l.ensureQueue(req.QueueName)
+
+	items, err := l.getQueueItems(req.QueueName)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to read queue",
+			err,
+		)
+	}
 
 	completeTime := time.Now()
 
 	// find the leased task
-	for i, queueItem := range l.queues[req.QueueName] {
-		if queueItem.lease != nil && queueItem.lease.Id == req.LeaseId {
-			if completeTime.Before(queueItem.lease.Expiry) {
+	for _, queueItem := range items {
+		if queueItem.Lease != nil && queueItem.Lease.Id == req.LeaseId {
+			if completeTime.Before(queueItem.Lease.Expiry) {
+				tx, err := l.begin(ctx)
+				if err != nil {
+					return nil, newErr(
+						codes.Internal,
+						"failed to open queue store",
+						err,
+					)
+				}
+
+				defer func() { _ = tx.Rollback() }()
+
 				// remove the leased task
-				l.queues[req.QueueName] = append(l.queues[req.QueueName][:i], l.queues[req.QueueName][i+1:]...)
+				err = tx.DeleteStruct(&queueItem)
+				if err != nil {
+					return nil, newErr(
+						codes.Internal,
+						"failed to remove message",
+						err,
+					)
+				}
+
+				tx.recordAction(&queueItem, QueueAction_Complete)
+
+				err = l.commit(tx)
+				if err != nil {
+					return nil, newErr(
+						codes.Internal,
+						"failed to remove message",
+						err,
+					)
+				}
+
 				return &queuespb.QueueCompleteResponse{}, nil
 			}
 
 			return nil, newErr(
 				codes.FailedPrecondition,
-				fmt.Sprintf("LeaseId: %s expired at %s, current time %s", req.LeaseId, queueItem.lease.Expiry, completeTime),
+				fmt.Sprintf("LeaseId: %s expired at %s, current time %s", req.LeaseId, queueItem.Lease.Expiry, completeTime),
 				nil,
 			)
 		}
@@ -165,11 +667,226 @@ func (l *LocalQueuesService) Complete(ctx context.Context, req *queuespb.QueueCo
 	)
 }
 
+// ExtendLease extends the lease of a dequeued message, keeping it hidden from other consumers for the given timeout.
+// If timeout is zero, the visibility timeout of the queue is used.
+func (l *LocalQueuesService) ExtendLease(ctx context.Context, queueName string, leaseId string, timeout time.Duration) (*Lease, error) {
+	newErr := grpc_errors.ErrorsWithScope("DevQueuesService.ExtendLease")
+
+	defer l.publishPendingActions()
+
+	l.queueLock.Lock()
+	defer l.queueLock.Unlock()
+
+	if timeout <= 0 {
+		timeout = l.visibilityTimeout(queueName)
+	}
+
+	items, err := l.getQueueItems(queueName)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to read queue",
+			err,
+		)
+	}
+
+	now := time.Now()
+
+	for _, queueItem := range items {
+		if queueItem.Lease != nil && queueItem.Lease.Id == leaseId {
+			if !queueItem.isLeased(now) {
+				return nil, newErr(
+					codes.FailedPrecondition,
+					fmt.Sprintf("LeaseId: %s expired at %s, current time %s", leaseId, queueItem.Lease.Expiry, now),
+					nil,
+				)
+			}
+
+			tx, err := l.begin(ctx)
+			if err != nil {
+				return nil, newErr(
+					codes.Internal,
+					"failed to open queue store",
+					err,
+				)
+			}
+
+			defer func() { _ = tx.Rollback() }()
+
+			queueItem.Lease.Expiry = now.Add(timeout)
+
+			tx.recordAction(&queueItem, QueueAction_ExtendLease)
+
+			err = tx.Save(&queueItem)
+			if err != nil {
+				return nil, newErr(
+					codes.Internal,
+					"failed to extend lease",
+					err,
+				)
+			}
+
+			err = l.commit(tx)
+			if err != nil {
+				return nil, newErr(
+					codes.Internal,
+					"failed to extend lease",
+					err,
+				)
+			}
+
+			return queueItem.Lease, nil
+		}
+	}
+
+	return nil, newErr(
+		codes.InvalidArgument,
+		fmt.Sprintf("LeaseId: %s not found", leaseId),
+		nil,
+	)
+}
+
+// Peek returns up to limit messages from a queue without leasing them, including messages currently in-flight
+func (l *LocalQueuesService) Peek(ctx context.Context, queueName string, limit int) ([]QueueMessageInfo, error) {
+	newErr := grpc_errors.ErrorsWithScope("DevQueuesService.Peek")
+
+	l.queueLock.Lock()
+	defer l.queueLock.Unlock()
+
+	items, err := l.getQueueItems(queueName)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to read queue",
+			err,
+		)
+	}
+
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	messages := []QueueMessageInfo{}
+	now := time.Now()
+
+	for _, item := range items {
+		message := QueueMessageInfo{
+			Id:           item.Id,
+			Payload:      item.payload(),
+			ReceiveCount: item.ReceiveCount,
+		}
+
+		if item.isLeased(now) {
+			message.LeaseId = item.Lease.Id
+			message.LeaseExpiry = &item.Lease.Expiry
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// Purge removes all messages from a queue, including messages currently in-flight
+func (l *LocalQueuesService) Purge(ctx context.Context, queueName string) error {
+	newErr := grpc_errors.ErrorsWithScope("DevQueuesService.Purge")
+
+	defer l.publishPendingActions()
+
+	l.queueLock.Lock()
+	defer l.queueLock.Unlock()
+
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"failed to open queue store",
+			err,
+		)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.Select(q.Eq("QueueName", queueName)).Delete(&QueueItem{})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return newErr(
+			codes.Internal,
+			"failed to purge queue",
+			err,
+		)
+	}
+
+	tx.actions = append(tx.actions, ActionState{
+		QueueName: queueName,
+		Action:    QueueAction_Purge,
+	})
+
+	err = l.commit(tx)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"failed to purge queue",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// Close the underlying queue store
+func (l *LocalQueuesService) Close() error {
+	l.queueLock.Lock()
+	defer l.queueLock.Unlock()
+
+	// the queues may already be closed, e.g. when the local cloud is stopped twice
+	select {
+	case <-l.stop:
+		return nil
+	default:
+		close(l.stop)
+	}
+
+	return l.db.Close()
+}
+
+type NewLocalQueuesServiceOpts struct {
+	LocalConfig localconfig.LocalConfiguration
+}
+
 // Create new Dev EventService
-func NewLocalQueuesService() (*LocalQueuesService, error) {
+func NewLocalQueuesService(opts NewLocalQueuesServiceOpts) (*LocalQueuesService, error) {
+	queuesDir := env.LOCAL_QUEUES_DIR.String()
+
+	// Check whether file exists
+	_, err := os.Stat(queuesDir)
+	if os.IsNotExist(err) {
+		// Make directory if not present
+		err := os.MkdirAll(queuesDir, 0o777)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options := storm.BoltOptions(0o600, &bbolt.Options{Timeout: 1 * time.Second})
+
+	db, err := storm.Open(filepath.Join(queuesDir, queuesDbName), options)
+	if err != nil {
+		return nil, err
+	}
+
+	config := opts.LocalConfig.Queues
+	if config == nil {
+		config = map[queueName]localconfig.LocalQueueConfiguration{}
+	}
+
 	queueService := &LocalQueuesService{
-		queues: map[queueName][]*QueueItem{},
+		db:     db,
+		config: config,
+		stop:   make(chan bool),
+		bus:    EventBus.New(),
 	}
 
+	go queueService.runLeaseSweeper()
+
 	return queueService, nil
 }
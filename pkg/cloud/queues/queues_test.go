@@ -0,0 +1,354 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queues
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nitrictech/cli/pkg/cloud/env"
+	"github.com/nitrictech/cli/pkg/project/localconfig"
+	coreenv "github.com/nitrictech/nitric/core/pkg/env"
+	queuespb "github.com/nitrictech/nitric/core/pkg/proto/queues/v1"
+)
+
+// setTestQueuesDir points the queue store at a temporary directory for the rest of the test
+func setTestQueuesDir(t *testing.T) {
+	t.Helper()
+
+	queuesDir := t.TempDir()
+
+	t.Setenv("LOCAL_QUEUES_DIR", queuesDir)
+
+	previous := env.LOCAL_QUEUES_DIR
+	env.LOCAL_QUEUES_DIR = coreenv.GetEnv("LOCAL_QUEUES_DIR", queuesDir)
+
+	t.Cleanup(func() {
+		env.LOCAL_QUEUES_DIR = previous
+	})
+}
+
+// newTestQueuesService opens the queue store in the test queues directory, closing it when the test ends.
+// Expired leases are only swept when the test calls sweepExpiredLeases, so actions are published in a predictable order.
+func newTestQueuesService(t *testing.T, localConfig localconfig.LocalConfiguration) *LocalQueuesService {
+	t.Helper()
+
+	previousInterval := leaseSweepInterval
+	leaseSweepInterval = time.Hour
+
+	t.Cleanup(func() {
+		leaseSweepInterval = previousInterval
+	})
+
+	l, err := NewLocalQueuesService(NewLocalQueuesServiceOpts{LocalConfig: localConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	return l
+}
+
+func testMessage(t *testing.T, id string) *queuespb.QueueMessage {
+	t.Helper()
+
+	payload, err := structpb.NewStruct(map[string]interface{}{"id": id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &queuespb.QueueMessage{
+		Content: &queuespb.QueueMessage_StructPayload{StructPayload: payload},
+	}
+}
+
+func enqueue(t *testing.T, l *LocalQueuesService, queueName string, ids ...string) {
+	t.Helper()
+
+	messages := []*queuespb.QueueMessage{}
+	for _, id := range ids {
+		messages = append(messages, testMessage(t, id))
+	}
+
+	_, err := l.Enqueue(context.TODO(), &queuespb.QueueEnqueueRequest{
+		QueueName: queueName,
+		Messages:  messages,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dequeue leases up to depth messages, returning their ids and lease ids
+func dequeue(t *testing.T, l *LocalQueuesService, queueName string, depth int32) ([]string, []string) {
+	t.Helper()
+
+	resp, err := l.Dequeue(context.TODO(), &queuespb.QueueDequeueRequest{
+		QueueName: queueName,
+		Depth:     depth,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	leaseIds := []string{}
+
+	for _, message := range resp.Messages {
+		ids = append(ids, message.Message.GetStructPayload().Fields["id"].GetStringValue())
+		leaseIds = append(leaseIds, message.LeaseId)
+	}
+
+	return ids, leaseIds
+}
+
+func TestQueuesRestart(t *testing.T) {
+	setTestQueuesDir(t)
+
+	l := newTestQueuesService(t, localconfig.LocalConfiguration{})
+
+	enqueue(t, l, "jobs", "1", "2", "3")
+
+	ids, leaseIds := dequeue(t, l, "jobs", 1)
+	if !cmp.Equal([]string{"1"}, ids) {
+		t.Fatal(cmp.Diff([]string{"1"}, ids))
+	}
+
+	err := l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// messages and their leases should survive the queues being restarted
+	l = newTestQueuesService(t, localconfig.LocalConfiguration{})
+
+	state, err := l.GetState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedState := State{"jobs": {Depth: 2, InFlight: 1}}
+	if !cmp.Equal(expectedState, state) {
+		t.Error(cmp.Diff(expectedState, state))
+	}
+
+	ids, _ = dequeue(t, l, "jobs", 10)
+	if !cmp.Equal([]string{"2", "3"}, ids) {
+		t.Error(cmp.Diff([]string{"2", "3"}, ids))
+	}
+
+	// a lease taken before the restart can still be completed
+	_, err = l.Complete(context.TODO(), &queuespb.QueueCompleteRequest{
+		QueueName: "jobs",
+		LeaseId:   leaseIds[0],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = l.GetState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedState = State{"jobs": {Depth: 0, InFlight: 2}}
+	if !cmp.Equal(expectedState, state) {
+		t.Error(cmp.Diff(expectedState, state))
+	}
+}
+
+func TestQueuesClose(t *testing.T) {
+	setTestQueuesDir(t)
+
+	l := newTestQueuesService(t, localconfig.LocalConfiguration{})
+
+	for i := 0; i < 2; i++ {
+		err := l.Close()
+		if err != nil {
+			t.Errorf("expected closing the queues %d times to succeed, got %v", i+1, err)
+		}
+	}
+}
+
+// expireLeases moves the expiry of every leased message into the past, as if the visibility timeout had passed
+func expireLeases(t *testing.T, l *LocalQueuesService) {
+	t.Helper()
+
+	l.queueLock.Lock()
+	defer l.queueLock.Unlock()
+
+	items := []QueueItem{}
+
+	err := l.db.All(&items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		if item.Lease == nil {
+			continue
+		}
+
+		item.Lease.Expiry = time.Now().Add(-time.Second)
+
+		err = l.db.Save(&item)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	setTestQueuesDir(t)
+
+	l := newTestQueuesService(t, localconfig.LocalConfiguration{
+		Queues: map[string]localconfig.LocalQueueConfiguration{
+			"jobs": {MaxReceiveCount: 2, DeadLetterQueue: "jobs-dlq"},
+		},
+	})
+
+	actions := []QueueAction{}
+	l.SubscribeToAction(func(action ActionState) {
+		actions = append(actions, action.Action)
+	})
+
+	enqueue(t, l, "jobs", "1")
+
+	// the message is received until its leases have expired MaxReceiveCount times
+	for i := 0; i < 2; i++ {
+		ids, _ := dequeue(t, l, "jobs", 1)
+		if !cmp.Equal([]string{"1"}, ids) {
+			t.Fatalf("receive %d: %s", i+1, cmp.Diff([]string{"1"}, ids))
+		}
+
+		expireLeases(t, l)
+	}
+
+	ids, _ := dequeue(t, l, "jobs", 1)
+	if len(ids) > 0 {
+		t.Errorf("expected no messages after %d receives, got %v", 2, ids)
+	}
+
+	state, err := l.GetState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedState := State{"jobs-dlq": {Depth: 1}}
+	if !cmp.Equal(expectedState, state) {
+		t.Error(cmp.Diff(expectedState, state))
+	}
+
+	expectedActions := []QueueAction{
+		QueueAction_Enqueue,
+		QueueAction_Dequeue,
+		QueueAction_LeaseExpired,
+		QueueAction_Dequeue,
+		QueueAction_LeaseExpired,
+		QueueAction_DeadLetter,
+	}
+	if !cmp.Equal(expectedActions, actions) {
+		t.Error(cmp.Diff(expectedActions, actions))
+	}
+
+	// the receive count starts again in the dead letter queue
+	messages, err := l.Peek(context.TODO(), "jobs-dlq", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 1 || messages[0].ReceiveCount != 0 || messages[0].Payload != `{"id":"1"}` {
+		t.Errorf("expected the message in the dead letter queue, got %+v", messages)
+	}
+
+	ids, _ = dequeue(t, l, "jobs-dlq", 1)
+	if !cmp.Equal([]string{"1"}, ids) {
+		t.Error(cmp.Diff([]string{"1"}, ids))
+	}
+}
+
+func TestSweepExtendedLease(t *testing.T) {
+	setTestQueuesDir(t)
+
+	l := newTestQueuesService(t, localconfig.LocalConfiguration{
+		Queues: map[string]localconfig.LocalQueueConfiguration{
+			"jobs": {VisibilityTimeout: 1},
+		},
+	})
+
+	enqueue(t, l, "jobs", "1", "2")
+
+	ids, leaseIds := dequeue(t, l, "jobs", 2)
+	if !cmp.Equal([]string{"1", "2"}, ids) {
+		t.Fatal(cmp.Diff([]string{"1", "2"}, ids))
+	}
+
+	lease, err := l.ExtendLease(context.TODO(), "jobs", leaseIds[0], time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lease.Id != leaseIds[0] {
+		t.Errorf("expected the extended lease to keep its id %s, got %s", leaseIds[0], lease.Id)
+	}
+
+	// wait out the visibility timeout, only the lease that wasn't extended should be swept
+	time.Sleep(1100 * time.Millisecond)
+
+	err = l.sweepExpiredLeases()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := l.Peek(context.TODO(), "jobs", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %+v", messages)
+	}
+
+	if messages[0].LeaseId != leaseIds[0] {
+		t.Errorf("expected message 1 to still be leased by %s, got %q", leaseIds[0], messages[0].LeaseId)
+	}
+
+	if messages[1].LeaseId != "" {
+		t.Errorf("expected the lease on message 2 to be swept, got %q", messages[1].LeaseId)
+	}
+
+	// the swept message can be received again, the extended lease can still be completed
+	ids, _ = dequeue(t, l, "jobs", 2)
+	if !cmp.Equal([]string{"2"}, ids) {
+		t.Error(cmp.Diff([]string{"2"}, ids))
+	}
+
+	_, err = l.Complete(context.TODO(), &queuespb.QueueCompleteRequest{
+		QueueName: "jobs",
+		LeaseId:   leaseIds[0],
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
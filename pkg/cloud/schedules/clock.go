@@ -0,0 +1,379 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedules
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/nitrictech/cli/pkg/system"
+	"github.com/nitrictech/nitric/core/pkg/logger"
+	schedulespb "github.com/nitrictech/nitric/core/pkg/proto/schedules/v1"
+)
+
+// maxAdvanceFires limits how many schedule runs a single clock advance can trigger,
+// e.g. a schedule running every second fast-forwarded by a year.
+const maxAdvanceFires = 10000
+
+const localSchedulesClockTopic = "local_schedules_clock"
+
+type ClockState struct {
+	// The current time of the virtual clock
+	Now time.Time `json:"now"`
+	// How far the virtual clock is ahead of real time, in milliseconds
+	Offset int64 `json:"offset"`
+}
+
+type ClockAdvanceResult struct {
+	// The number of schedule runs triggered by the advance
+	Runs  int        `json:"runs"`
+	Clock ClockState `json:"clock"`
+}
+
+type scheduleEntry struct {
+	schedule cron.Schedule
+	next     time.Time
+}
+
+type scheduledFire struct {
+	scheduleName string
+	at           time.Time
+}
+
+// Now returns the current time of the virtual clock used to run local schedules
+func (l *LocalSchedulesService) Now() time.Time {
+	l.clockLock.Lock()
+	defer l.clockLock.Unlock()
+
+	return l.now()
+}
+
+func (l *LocalSchedulesService) now() time.Time {
+	return time.Now().Add(l.clockOffset)
+}
+
+func (l *LocalSchedulesService) GetClockState() ClockState {
+	l.clockLock.Lock()
+	defer l.clockLock.Unlock()
+
+	return l.clockState()
+}
+
+func (l *LocalSchedulesService) clockState() ClockState {
+	return ClockState{
+		Now:    l.now(),
+		Offset: l.clockOffset.Milliseconds(),
+	}
+}
+
+func (l *LocalSchedulesService) SubscribeToClock(fn func(ClockState)) {
+	// ignore the error, it's only returned if the fn param isn't a function
+	_ = l.bus.Subscribe(localSchedulesClockTopic, fn)
+}
+
+// wake interrupts the scheduler loop so it recalculates the next fire time
+func (l *LocalSchedulesService) wake() {
+	select {
+	case l.wakeScheduler <- struct{}{}:
+	default:
+	}
+}
+
+func (l *LocalSchedulesService) addScheduleEntry(scheduleName, expression string) error {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return err
+	}
+
+	l.clockLock.Lock()
+	l.entries[scheduleName] = &scheduleEntry{
+		schedule: schedule,
+		next:     schedule.Next(l.now()),
+	}
+	l.clockLock.Unlock()
+
+	l.wake()
+
+	return nil
+}
+
+func (l *LocalSchedulesService) removeScheduleEntry(scheduleName string) {
+	l.clockLock.Lock()
+	delete(l.entries, scheduleName)
+	l.clockLock.Unlock()
+
+	l.wake()
+}
+
+func (l *LocalSchedulesService) runSchedule(scheduleName string, action ActionState) {
+	_, err := l.handleRequest(&schedulespb.ServerMessage{
+		Content: &schedulespb.ServerMessage_IntervalRequest{
+			IntervalRequest: &schedulespb.IntervalRequest{
+				ScheduleName: scheduleName,
+			},
+		},
+	}, action)
+	if err != nil {
+		logger.Errorf("Error handling schedule: %s", err.Error())
+	}
+}
+
+// trigger runs a schedule that has fallen due, applying its overlap policy if the previous run is still in progress
+func (l *LocalSchedulesService) trigger(scheduleName string) {
+	// the policy is validated when the schedule is registered
+	policy, _ := l.overlapPolicy(scheduleName)
+
+	if policy == OverlapAllow {
+		l.runSchedule(scheduleName, ActionState{})
+		return
+	}
+
+	l.runsLock.Lock()
+
+	runs, ok := l.runs[scheduleName]
+	if !ok {
+		runs = &scheduleRuns{}
+		l.runs[scheduleName] = runs
+	}
+
+	if runs.running {
+		if policy == OverlapSkip {
+			l.runsLock.Unlock()
+			l.publishAction(ActionState{ScheduleName: scheduleName, Skipped: true})
+
+			return
+		}
+
+		// the run in progress will run queued runs once it completes
+		runs.queued = append(runs.queued, time.Now())
+		l.runsLock.Unlock()
+
+		return
+	}
+
+	runs.running = true
+	l.runsLock.Unlock()
+
+	action := ActionState{}
+
+	for {
+		l.runSchedule(scheduleName, action)
+
+		l.runsLock.Lock()
+
+		if len(runs.queued) == 0 {
+			runs.running = false
+			l.runsLock.Unlock()
+
+			return
+		}
+
+		action = ActionState{Queued: true, Wait: time.Since(runs.queued[0])}
+		runs.queued = runs.queued[1:]
+
+		l.runsLock.Unlock()
+	}
+}
+
+// runScheduler fires schedules as the virtual clock reaches their next run time
+func (l *LocalSchedulesService) runScheduler() {
+	for {
+		l.clockLock.Lock()
+		now := l.now()
+
+		due := []string{}
+		earliest := time.Time{}
+
+		for name, entry := range l.entries {
+			// cron expressions that can never match, e.g. 30th of February, have no next run
+			if entry.next.IsZero() {
+				continue
+			}
+
+			if !entry.next.After(now) {
+				if !l.paused[name] {
+					due = append(due, name)
+				}
+
+				entry.next = entry.schedule.Next(now)
+			}
+
+			if !entry.next.IsZero() && (earliest.IsZero() || entry.next.Before(earliest)) {
+				earliest = entry.next
+			}
+		}
+		l.clockLock.Unlock()
+
+		for _, name := range due {
+			go l.trigger(name)
+		}
+
+		var timer *time.Timer
+		var timerC <-chan time.Time
+
+		if !earliest.IsZero() {
+			timer = time.NewTimer(earliest.Sub(now))
+			timerC = timer.C
+		}
+
+		select {
+		case <-timerC:
+		case <-l.wakeScheduler:
+		case <-l.stopScheduler:
+			if timer != nil {
+				timer.Stop()
+			}
+
+			return
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// AdvanceClock moves the virtual clock forward, running every schedule that would have fired in the skipped window in order.
+// Returns the number of schedule runs that were triggered.
+func (l *LocalSchedulesService) AdvanceClock(duration time.Duration) (int, error) {
+	if duration <= 0 {
+		return 0, fmt.Errorf("clock can only be advanced by a positive duration, got %s", duration)
+	}
+
+	l.advanceLock.Lock()
+	defer l.advanceLock.Unlock()
+
+	l.clockLock.Lock()
+
+	target := l.now().Add(duration)
+
+	// work out the runs before updating any entries, so a rejected advance leaves the schedules untouched
+	nextRuns := make(map[string]time.Time, len(l.entries))
+	fires := []scheduledFire{}
+
+	for name, entry := range l.entries {
+		if l.paused[name] {
+			nextRuns[name] = entry.schedule.Next(target)
+			continue
+		}
+
+		next := entry.next
+
+		for !next.IsZero() && !next.After(target) {
+			if len(fires) >= maxAdvanceFires {
+				l.clockLock.Unlock()
+
+				return 0, fmt.Errorf("advancing the clock by %s would run more than %d schedules, use a shorter duration", duration, maxAdvanceFires)
+			}
+
+			fires = append(fires, scheduledFire{scheduleName: name, at: next})
+			next = entry.schedule.Next(next)
+		}
+
+		nextRuns[name] = next
+	}
+
+	for name, next := range nextRuns {
+		l.entries[name].next = next
+	}
+
+	l.clockOffset += duration
+	state := l.clockState()
+
+	l.clockLock.Unlock()
+
+	sort.SliceStable(fires, func(i, j int) bool {
+		if fires[i].at.Equal(fires[j].at) {
+			return fires[i].scheduleName < fires[j].scheduleName
+		}
+
+		return fires[i].at.Before(fires[j].at)
+	})
+
+	for _, fire := range fires {
+		system.Logf("Running schedule %s for %s", fire.scheduleName, fire.at.Format(time.RFC3339))
+		l.trigger(fire.scheduleName)
+	}
+
+	l.bus.Publish(localSchedulesClockTopic, state)
+	l.wake()
+
+	return len(fires), nil
+}
+
+// NextRuns returns the next times a schedule will run according to the virtual clock
+func (l *LocalSchedulesService) NextRuns(scheduleName string, count int) ([]time.Time, error) {
+	l.clockLock.Lock()
+	defer l.clockLock.Unlock()
+
+	entry, ok := l.entries[scheduleName]
+	if !ok {
+		return nil, fmt.Errorf("schedule %s is not running", scheduleName)
+	}
+
+	// report cron run times in the timezone of the schedule
+	location := time.Local
+	if spec, ok := entry.schedule.(*cron.SpecSchedule); ok {
+		location = spec.Location
+	}
+
+	nextRuns := []time.Time{}
+
+	for next := entry.next; !next.IsZero() && len(nextRuns) < count; next = entry.schedule.Next(next) {
+		nextRuns = append(nextRuns, next.In(location))
+	}
+
+	return nextRuns, nil
+}
+
+// ResetClock returns the virtual clock to real time, without running any schedules
+func (l *LocalSchedulesService) ResetClock() {
+	l.advanceLock.Lock()
+	defer l.advanceLock.Unlock()
+
+	l.clockLock.Lock()
+
+	l.clockOffset = 0
+	now := l.now()
+
+	for _, entry := range l.entries {
+		entry.next = entry.schedule.Next(now)
+	}
+
+	state := l.clockState()
+
+	l.clockLock.Unlock()
+
+	l.bus.Publish(localSchedulesClockTopic, state)
+	l.wake()
+}
+
+// Stop the local schedule runner
+func (l *LocalSchedulesService) Stop() {
+	l.clockLock.Lock()
+	defer l.clockLock.Unlock()
+
+	// the runner may already be stopped, e.g. when the local cloud is stopped twice
+	select {
+	case <-l.stopScheduler:
+	default:
+		close(l.stopScheduler)
+	}
+}
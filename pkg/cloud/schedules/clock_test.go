@@ -0,0 +1,318 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedules
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/nitrictech/cli/pkg/project/localconfig"
+	schedulespb "github.com/nitrictech/nitric/core/pkg/proto/schedules/v1"
+)
+
+// newTestSchedulesService returns a schedules service with the given schedule entries, and a func returning the names of the schedules run so far
+func newTestSchedulesService(t *testing.T, expressions map[string]string) (*LocalSchedulesService, func() []string) {
+	t.Helper()
+
+	l := NewLocalSchedulesService(NewLocalSchedulesServiceOpts{
+		ErrorLogger: func(serviceName string, err error) {},
+		LocalConfig: localconfig.LocalConfiguration{},
+	})
+	t.Cleanup(l.Stop)
+
+	for name, expression := range expressions {
+		err := l.registerSchedule("test-service", &schedulespb.RegistrationRequest{
+			ScheduleName: name,
+			Cadence: &schedulespb.RegistrationRequest_Cron{
+				Cron: &schedulespb.ScheduleCron{Expression: expression},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = l.addScheduleEntry(name, expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runsLock := sync.Mutex{}
+	runs := []string{}
+
+	l.SubscribeToAction(func(action ActionState) {
+		runsLock.Lock()
+		defer runsLock.Unlock()
+
+		runs = append(runs, action.ScheduleName)
+	})
+
+	return l, func() []string {
+		runsLock.Lock()
+		defer runsLock.Unlock()
+
+		return append([]string{}, runs...)
+	}
+}
+
+func TestAdvanceClock(t *testing.T) {
+	for i, tt := range []struct {
+		expressions map[string]string
+		paused      []string
+		duration    time.Duration
+		// counts of the runs expected for each schedule
+		expected map[string]int
+	}{
+		{
+			expressions: map[string]string{"hourly": "0 * * * *"},
+			duration:    3 * time.Hour,
+			expected:    map[string]int{"hourly": 3},
+		},
+		{
+			expressions: map[string]string{"hourly": "0 * * * *", "half-hourly": "*/30 * * * *"},
+			duration:    2 * time.Hour,
+			expected:    map[string]int{"hourly": 2, "half-hourly": 4},
+		},
+		{
+			expressions: map[string]string{"yearly": "0 0 1 1 *"},
+			duration:    time.Second,
+			expected:    map[string]int{},
+		},
+		{
+			expressions: map[string]string{"hourly": "0 * * * *", "paused": "0 * * * *"},
+			paused:      []string{"paused"},
+			duration:    3 * time.Hour,
+			expected:    map[string]int{"hourly": 3},
+		},
+	} {
+		t.Run(fmt.Sprintf("test AdvanceClock: %d", i), func(t *testing.T) {
+			l, runs := newTestSchedulesService(t, tt.expressions)
+
+			for _, name := range tt.paused {
+				if err := l.PauseSchedule(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the runs expected in order of their fire time, runs due at the same time are in order of schedule name
+			type fire struct {
+				name string
+				at   time.Time
+			}
+
+			target := l.Now().Add(tt.duration)
+			fires := []fire{}
+
+			for name, count := range tt.expected {
+				nextRuns, err := l.NextRuns(name, count)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, at := range nextRuns {
+					if at.After(target) {
+						t.Fatalf("expected %d runs of %s before %s, next run is at %s", count, name, target, at)
+					}
+
+					fires = append(fires, fire{name: name, at: at})
+				}
+			}
+
+			sort.Slice(fires, func(i, j int) bool {
+				if fires[i].at.Equal(fires[j].at) {
+					return fires[i].name < fires[j].name
+				}
+
+				return fires[i].at.Before(fires[j].at)
+			})
+
+			expected := []string{}
+			for _, f := range fires {
+				expected = append(expected, f.name)
+			}
+
+			count, err := l.AdvanceClock(tt.duration)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count != len(expected) {
+				t.Errorf("expected %d runs, got %d", len(expected), count)
+			}
+
+			if actual := runs(); !cmp.Equal(expected, actual) {
+				t.Error(cmp.Diff(expected, actual))
+			}
+
+			if offset := l.GetClockState().Offset; offset != tt.duration.Milliseconds() {
+				t.Errorf("expected clock offset of %d, got %d", tt.duration.Milliseconds(), offset)
+			}
+
+			// every schedule, including paused schedules, should next run after the advanced clock
+			for name := range tt.expressions {
+				nextRuns, err := l.NextRuns(name, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(nextRuns) != 1 || !nextRuns[0].After(l.Now()) {
+					t.Errorf("expected %s to next run after %s, got %v", name, l.Now(), nextRuns)
+				}
+			}
+		})
+	}
+}
+
+func TestAdvanceClockRejected(t *testing.T) {
+	for i, tt := range []struct {
+		expressions map[string]string
+		duration    time.Duration
+	}{
+		{
+			expressions: map[string]string{"hourly": "0 * * * *"},
+			duration:    0,
+		},
+		{
+			expressions: map[string]string{"hourly": "0 * * * *"},
+			duration:    -time.Hour,
+		},
+		{
+			// more than maxAdvanceFires runs
+			expressions: map[string]string{"every-minute": "@every 1m"},
+			duration:    240 * time.Hour,
+		},
+		{
+			// more than maxAdvanceFires runs across schedules
+			expressions: map[string]string{"hourly": "0 * * * *", "every-hour": "@every 1h"},
+			duration:    6000 * time.Hour,
+		},
+	} {
+		t.Run(fmt.Sprintf("test AdvanceClockRejected: %d", i), func(t *testing.T) {
+			l, runs := newTestSchedulesService(t, tt.expressions)
+
+			nextRuns := map[string][]time.Time{}
+
+			for name := range tt.expressions {
+				next, err := l.NextRuns(name, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				nextRuns[name] = next
+			}
+
+			_, err := l.AdvanceClock(tt.duration)
+			if err == nil {
+				t.Fatalf("expected advancing the clock by %s to be rejected", tt.duration)
+			}
+
+			if actual := runs(); len(actual) > 0 {
+				t.Errorf("expected no runs, got %v", actual)
+			}
+
+			if offset := l.GetClockState().Offset; offset != 0 {
+				t.Errorf("expected the clock to be unchanged, got an offset of %d", offset)
+			}
+
+			// a rejected advance should leave the schedules untouched
+			for name, expected := range nextRuns {
+				actual, err := l.NextRuns(name, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !cmp.Equal(expected, actual) {
+					t.Error(cmp.Diff(expected, actual))
+				}
+			}
+		})
+	}
+}
+
+func TestNextRuns(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		expression string
+		count      int
+		location   *time.Location
+		interval   time.Duration
+	}{
+		{
+			expression: "@every 90m",
+			count:      3,
+			location:   time.Local,
+			interval:   90 * time.Minute,
+		},
+		{
+			expression: "CRON_TZ=UTC */15 * * * *",
+			count:      5,
+			location:   time.UTC,
+			interval:   15 * time.Minute,
+		},
+		{
+			expression: "CRON_TZ=Australia/Sydney 30 * * * *",
+			count:      4,
+			location:   sydney,
+			interval:   time.Hour,
+		},
+	} {
+		t.Run(fmt.Sprintf("test NextRuns: %d", i), func(t *testing.T) {
+			l, _ := newTestSchedulesService(t, map[string]string{"test": tt.expression})
+
+			nextRuns, err := l.NextRuns("test", tt.count)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(nextRuns) != tt.count {
+				t.Fatalf("expected %d runs, got %d", tt.count, len(nextRuns))
+			}
+
+			if !nextRuns[0].After(l.Now()) {
+				t.Errorf("expected the first run %s to be after %s", nextRuns[0], l.Now())
+			}
+
+			for j, next := range nextRuns {
+				if next.Location().String() != tt.location.String() {
+					t.Errorf("expected run %d in %s, got %s", j, tt.location, next.Location())
+				}
+
+				if j > 0 && next.Sub(nextRuns[j-1]) != tt.interval {
+					t.Errorf("expected runs %s apart, got %s between %s and %s", tt.interval, next.Sub(nextRuns[j-1]), nextRuns[j-1], next)
+				}
+			}
+		})
+	}
+
+	t.Run("test NextRuns: unknown schedule", func(t *testing.T) {
+		l, _ := newTestSchedulesService(t, map[string]string{})
+
+		_, err := l.NextRuns("unknown", 1)
+		if err == nil {
+			t.Error("expected an error for a schedule that is not running")
+		}
+	})
+}
@@ -19,17 +19,20 @@ package schedules
 import (
 	"fmt"
 	"maps"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	// embed the timezone database, so schedule timezones resolve on hosts without one
+	_ "time/tzdata"
 
 	"github.com/asaskevich/EventBus"
-	"github.com/robfig/cron/v3"
 
 	"github.com/nitrictech/cli/pkg/cloud/errorsx"
 	"github.com/nitrictech/cli/pkg/grpcx"
+	"github.com/nitrictech/cli/pkg/project/localconfig"
 	"github.com/nitrictech/cli/pkg/validation"
-	"github.com/nitrictech/nitric/core/pkg/logger"
 	resourcespb "github.com/nitrictech/nitric/core/pkg/proto/resources/v1"
 	schedulespb "github.com/nitrictech/nitric/core/pkg/proto/schedules/v1"
 	"github.com/nitrictech/nitric/core/pkg/workers/schedules"
@@ -43,22 +46,77 @@ type (
 type ScheduledService struct {
 	ServiceName serviceName
 	Schedule    *schedulespb.RegistrationRequest
+	// Paused schedules are not run by the scheduler, but can still be triggered manually
+	Paused bool
+	// The timezone cron expressions are evaluated in, empty for rate based schedules
+	Timezone string
 }
 
 type State = map[scheduleName]*ScheduledService
 
+type ScheduleInfo struct {
+	Name        string      `json:"name"`
+	ServiceName string      `json:"serviceName"`
+	Expression  string      `json:"expression,omitempty"`
+	Rate        string      `json:"rate,omitempty"`
+	Timezone    string      `json:"timezone,omitempty"`
+	Paused      bool        `json:"paused"`
+	NextRuns    []time.Time `json:"nextRuns"`
+}
+
 type ActionState struct {
 	ScheduleName string
 	Success      bool
+	// Skipped runs were due while a previous run was in progress and did not run
+	Skipped bool
+	// Queued runs were due while a previous run was in progress and ran after it completed
+	Queued bool
+	// How long a queued run waited for the previous run to complete
+	Wait time.Duration
+	// How long the schedule handler took to run
+	Duration time.Duration
+	// Error returned while running the schedule handler, if any
+	Error string
+}
+
+type OverlapPolicy string
+
+const (
+	// OverlapAllow runs the schedule again even if the previous run is in progress
+	OverlapAllow OverlapPolicy = "allow"
+	// OverlapSkip skips runs that are due while the previous run is in progress
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue delays runs that are due while the previous run is in progress until it completes
+	OverlapQueue OverlapPolicy = "queue"
+)
+
+type scheduleRuns struct {
+	running bool
+	// the due time of each queued run
+	queued []time.Time
 }
 
 type LocalSchedulesService struct {
 	*schedules.ScheduleWorkerManager
-	cron *cron.Cron
 
 	schedulesLock sync.RWMutex
 
+	// the virtual clock and the next run of each schedule against it
+	clockLock     sync.Mutex
+	advanceLock   sync.Mutex
+	clockOffset   time.Duration
+	entries       map[scheduleName]*scheduleEntry
+	wakeScheduler chan struct{}
+	stopScheduler chan struct{}
+
+	// paused schedules are kept separately from their entries, so a schedule stays paused when its service restarts
+	paused map[scheduleName]bool
+
+	runsLock sync.Mutex
+	runs     map[scheduleName]*scheduleRuns
+
 	errorLogger errorsx.ServiceErrorLogger
+	localConfig localconfig.LocalConfiguration
 
 	schedules State
 	bus       EventBus.Bus
@@ -95,6 +153,38 @@ func (l *LocalSchedulesService) GetSchedules() State {
 	return l.schedules
 }
 
+// GetScheduleInfo returns the registered schedules, sorted by name, including their next run times
+func (l *LocalSchedulesService) GetScheduleInfo(nextRunCount int) []ScheduleInfo {
+	l.schedulesLock.RLock()
+	defer l.schedulesLock.RUnlock()
+
+	scheduleInfo := []ScheduleInfo{}
+
+	for name, scheduled := range l.schedules {
+		// the schedule may be registered before its runner is created
+		nextRuns, err := l.NextRuns(name, nextRunCount)
+		if err != nil {
+			nextRuns = []time.Time{}
+		}
+
+		scheduleInfo = append(scheduleInfo, ScheduleInfo{
+			Name:        name,
+			ServiceName: scheduled.ServiceName,
+			Expression:  scheduled.Schedule.GetCron().GetExpression(),
+			Rate:        scheduled.Schedule.GetEvery().GetRate(),
+			Timezone:    scheduled.Timezone,
+			Paused:      scheduled.Paused,
+			NextRuns:    nextRuns,
+		})
+	}
+
+	sort.Slice(scheduleInfo, func(i, j int) bool {
+		return scheduleInfo[i].Name < scheduleInfo[j].Name
+	})
+
+	return scheduleInfo
+}
+
 func (l *LocalSchedulesService) registerSchedule(serviceName string, registrationRequest *schedulespb.RegistrationRequest) error {
 	l.schedulesLock.Lock()
 	defer l.schedulesLock.Unlock()
@@ -114,9 +204,20 @@ func (l *LocalSchedulesService) registerSchedule(serviceName string, registratio
 		return fmt.Errorf("conflict: schedule \"%s\" already taken by service %s", existing.Schedule.ScheduleName, existing.ServiceName)
 	}
 
+	l.clockLock.Lock()
+	paused := l.paused[registrationRequest.ScheduleName]
+	l.clockLock.Unlock()
+
+	timezone := ""
+	if registrationRequest.GetCron() != nil {
+		timezone = l.cronTimezone(registrationRequest.ScheduleName, registrationRequest.GetCron().Expression)
+	}
+
 	l.schedules[registrationRequest.ScheduleName] = &ScheduledService{
 		ServiceName: serviceName,
 		Schedule:    registrationRequest,
+		Paused:      paused,
+		Timezone:    timezone,
 	}
 
 	l.publishState()
@@ -133,29 +234,102 @@ func (l *LocalSchedulesService) unregisterSchedule(serviceName string, registrat
 	l.publishState()
 }
 
+func (l *LocalSchedulesService) setPaused(scheduleName string, paused bool) error {
+	l.schedulesLock.Lock()
+	defer l.schedulesLock.Unlock()
+
+	existing, ok := l.schedules[scheduleName]
+	if !ok {
+		return fmt.Errorf("schedule %s not found", scheduleName)
+	}
+
+	l.clockLock.Lock()
+	if paused {
+		l.paused[scheduleName] = true
+	} else {
+		delete(l.paused, scheduleName)
+	}
+	l.clockLock.Unlock()
+
+	// replace rather than modify the registration, previously published state may still be in use
+	l.schedules[scheduleName] = &ScheduledService{
+		ServiceName: existing.ServiceName,
+		Schedule:    existing.Schedule,
+		Paused:      paused,
+		Timezone:    existing.Timezone,
+	}
+
+	l.publishState()
+
+	return nil
+}
+
+// PauseSchedule stops a schedule from running until it is resumed, manual triggers still run the schedule
+func (l *LocalSchedulesService) PauseSchedule(scheduleName string) error {
+	return l.setPaused(scheduleName, true)
+}
+
+// ResumeSchedule runs a paused schedule again from its next fire time, runs missed while paused are skipped
+func (l *LocalSchedulesService) ResumeSchedule(scheduleName string) error {
+	return l.setPaused(scheduleName, false)
+}
+
 func (l *LocalSchedulesService) HandleRequest(request *schedulespb.ServerMessage) (*schedulespb.ClientMessage, error) {
+	return l.handleRequest(request, ActionState{})
+}
+
+func (l *LocalSchedulesService) handleRequest(request *schedulespb.ServerMessage, action ActionState) (*schedulespb.ClientMessage, error) {
+	start := time.Now()
+
 	resp, err := l.ScheduleWorkerManager.HandleRequest(request)
 
-	scheduleName := request.GetIntervalRequest().ScheduleName
+	action.ScheduleName = request.GetIntervalRequest().ScheduleName
+	action.Success = err == nil && resp.GetIntervalResponse() != nil
+	action.Duration = time.Since(start)
+
+	if err != nil {
+		action.Error = err.Error()
+	}
 
-	l.publishAction(ActionState{ScheduleName: scheduleName, Success: true})
+	l.publishAction(action)
 
 	return resp, err
 }
 
-func (l *LocalSchedulesService) createCronSchedule(scheduleName, expression string) (cron.EntryID, error) {
-	return l.cron.AddFunc(expression, func() {
-		_, err := l.HandleRequest(&schedulespb.ServerMessage{
-			Content: &schedulespb.ServerMessage_IntervalRequest{
-				IntervalRequest: &schedulespb.IntervalRequest{
-					ScheduleName: scheduleName,
-				},
-			},
-		})
-		if err != nil {
-			logger.Errorf("Error handling schedule: %s", err.Error())
+func (l *LocalSchedulesService) overlapPolicy(scheduleName string) (OverlapPolicy, error) {
+	policy := OverlapPolicy(l.localConfig.Schedules[scheduleName].Overlap)
+
+	switch policy {
+	case "":
+		return OverlapAllow, nil
+	case OverlapAllow, OverlapSkip, OverlapQueue:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid overlap policy %q for schedule %s, must be one of: allow, skip, queue", policy, scheduleName)
+	}
+}
+
+// cronTimezone returns the timezone a cron expression is evaluated in.
+// A CRON_TZ= or TZ= prefix on the expression takes precedence over the schedule and project configuration, matching cloud schedulers the default is UTC.
+func (l *LocalSchedulesService) cronTimezone(scheduleName, expression string) string {
+	expression = strings.TrimSpace(expression)
+
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(expression, prefix) {
+			timezone, _, _ := strings.Cut(strings.TrimPrefix(expression, prefix), " ")
+			return timezone
 		}
-	})
+	}
+
+	if timezone := l.localConfig.Schedules[scheduleName].Timezone; timezone != "" {
+		return timezone
+	}
+
+	if l.localConfig.Timezone != "" {
+		return l.localConfig.Timezone
+	}
+
+	return "UTC"
 }
 
 func (l *LocalSchedulesService) Schedule(stream schedulespb.Schedules_ScheduleServer) error {
@@ -186,9 +360,20 @@ func (l *LocalSchedulesService) Schedule(stream schedulespb.Schedules_ScheduleSe
 	scheduleName := firstRequest.GetRegistrationRequest().ScheduleName
 	cronExpression := ""
 
+	_, err = l.overlapPolicy(scheduleName)
+	if err != nil {
+		l.errorLogger(serviceName, err)
+		return nil
+	}
+
 	switch t := firstRequest.GetRegistrationRequest().Cadence.(type) {
 	case *schedulespb.RegistrationRequest_Cron:
-		cronExpression = t.Cron.Expression
+		cronExpression = strings.TrimSpace(t.Cron.Expression)
+
+		// evaluate the expression in the configured timezone, rather than the timezone of the host
+		if !strings.HasPrefix(cronExpression, "CRON_TZ=") && !strings.HasPrefix(cronExpression, "TZ=") {
+			cronExpression = fmt.Sprintf("CRON_TZ=%s %s", l.cronTimezone(scheduleName, cronExpression), cronExpression)
+		}
 	case *schedulespb.RegistrationRequest_Every:
 		parts := strings.Split(strings.TrimSpace(t.Every.Rate), " ")
 		if len(parts) != 2 {
@@ -213,25 +398,37 @@ func (l *LocalSchedulesService) Schedule(stream schedulespb.Schedules_ScheduleSe
 		return fmt.Errorf("unknown schedule type, must be one of: cron, every")
 	}
 
-	cronEntryId, err := l.createCronSchedule(scheduleName, cronExpression)
+	err = l.addScheduleEntry(scheduleName, cronExpression)
 	if err != nil {
-		return err
+		l.errorLogger(serviceName, fmt.Errorf("invalid schedule %s: %w", scheduleName, err))
+		return nil
 	}
 
-	defer l.cron.Remove(cronEntryId)
-
-	// Start the cron scheduler
-	l.cron.Start()
+	defer l.removeScheduleEntry(scheduleName)
 
 	return l.ScheduleWorkerManager.Schedule(peekableStream)
 }
 
-func NewLocalSchedulesService(errorLogger errorsx.ServiceErrorLogger) *LocalSchedulesService {
-	return &LocalSchedulesService{
-		errorLogger:           errorLogger,
+type NewLocalSchedulesServiceOpts struct {
+	ErrorLogger errorsx.ServiceErrorLogger
+	LocalConfig localconfig.LocalConfiguration
+}
+
+func NewLocalSchedulesService(opts NewLocalSchedulesServiceOpts) *LocalSchedulesService {
+	l := &LocalSchedulesService{
+		errorLogger:           opts.ErrorLogger,
+		localConfig:           opts.LocalConfig,
 		ScheduleWorkerManager: schedules.New(),
-		cron:                  cron.New(),
 		bus:                   EventBus.New(),
 		schedules:             make(State),
+		entries:               make(map[scheduleName]*scheduleEntry),
+		paused:                make(map[scheduleName]bool),
+		runs:                  make(map[scheduleName]*scheduleRuns),
+		wakeScheduler:         make(chan struct{}, 1),
+		stopScheduler:         make(chan struct{}),
 	}
+
+	go l.runScheduler()
+
+	return l
 }
@@ -0,0 +1,174 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/nitrictech/cli/pkg/passphrase"
+	"github.com/nitrictech/cli/pkg/paths"
+)
+
+// encryptedPrefix marks secret files encrypted at rest, files without it were written before secrets were encrypted
+const encryptedPrefix = "nitric:v1:"
+
+// keyDerivationLabel separates the secrets key from other uses of the local passphrase
+const keyDerivationLabel = "nitric local secrets"
+
+// newSecretsCipher returns the cipher used to encrypt secrets at rest, keyed by the local passphrase, which is generated if it doesn't exist
+func newSecretsCipher() (cipher.AEAD, error) {
+	passphrasePath, err := passphrase.GetOrGenerateFile(afero.NewOsFs())
+	if err != nil {
+		return nil, fmt.Errorf("error reading local passphrase: %w", err)
+	}
+
+	localPassphrase, err := os.ReadFile(passphrasePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading local passphrase: %w", err)
+	}
+
+	// the passphrase is random, so a single HMAC is enough to derive a key from it
+	mac := hmac.New(sha256.New, []byte(strings.TrimSpace(string(localPassphrase))))
+	_, _ = mac.Write([]byte(keyDerivationLabel))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func isEncrypted(content []byte) bool {
+	return strings.HasPrefix(string(content), encryptedPrefix)
+}
+
+// encrypt returns the content of a secret file encrypted at rest.
+// The file name is authenticated with the content, so an encrypted value can't be copied to another secret or version's file.
+func (s *DevSecretService) encrypt(fileName string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, s.cipher.NonceSize())
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := s.cipher.Seal(nonce, nonce, plaintext, []byte(fileName))
+
+	return []byte(encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// decrypt returns the plaintext content of the secret file with the given name, files written before secrets were encrypted are returned as is
+func (s *DevSecretService) decrypt(fileName string, content []byte) ([]byte, error) {
+	if !isEncrypted(content) {
+		return content, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(string(content), encryptedPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < s.cipher.NonceSize() {
+		return nil, fmt.Errorf("encrypted secret is too short")
+	}
+
+	nonce, ciphertext := sealed[:s.cipher.NonceSize()], sealed[s.cipher.NonceSize():]
+
+	plaintext, err := s.cipher.Open(nil, nonce, ciphertext, []byte(fileName))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt secret, the local passphrase at %s may have changed: %w", paths.NitricLocalPassphrasePath(), err)
+	}
+
+	return plaintext, nil
+}
+
+// writeSecretFile encrypts and writes a secret file, readable only by the current user
+func (s *DevSecretService) writeSecretFile(path string, plaintext []byte) error {
+	content, err := s.encrypt(filepath.Base(path), plaintext)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0o600)
+}
+
+// migrate encrypts the secret files written before secrets were encrypted, keeping their modified times as versions are ordered by them
+func (s *DevSecretService) migrate() error {
+	entries, err := os.ReadDir(s.secDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+
+		path := filepath.Join(s.secDir, entry.Name())
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if isEncrypted(content) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := s.encrypt(entry.Name(), content)
+		if err != nil {
+			return err
+		}
+
+		// write to a temporary file first, so an interrupted migration doesn't lose the secret
+		tmpPath := path + ".tmp"
+
+		err = os.WriteFile(tmpPath, encrypted, 0o600)
+		if err != nil {
+			return err
+		}
+
+		err = os.Chtimes(tmpPath, info.ModTime(), info.ModTime())
+		if err != nil {
+			return err
+		}
+
+		err = os.Rename(tmpPath, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
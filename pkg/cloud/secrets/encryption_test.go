@@ -0,0 +1,262 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nitrictech/cli/pkg/cloud/env"
+	"github.com/nitrictech/cli/pkg/paths"
+	coreenv "github.com/nitrictech/nitric/core/pkg/env"
+	secretspb "github.com/nitrictech/nitric/core/pkg/proto/secrets/v1"
+)
+
+// setTestSecretsDirs points the secrets and the local passphrase at temporary directories, returning the secrets directory
+func setTestSecretsDirs(t *testing.T) string {
+	t.Helper()
+
+	secretsDir := t.TempDir()
+
+	t.Setenv("NITRIC_HOME", t.TempDir())
+	t.Setenv("LOCAL_SECRETS_DIR", secretsDir)
+
+	previous := env.LOCAL_SECRETS_DIR
+	env.LOCAL_SECRETS_DIR = coreenv.GetEnv("LOCAL_SECRETS_DIR", secretsDir)
+
+	t.Cleanup(func() {
+		env.LOCAL_SECRETS_DIR = previous
+	})
+
+	return secretsDir
+}
+
+func newTestSecretService(t *testing.T) *DevSecretService {
+	t.Helper()
+
+	s, err := NewSecretService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func putSecret(t *testing.T, s *DevSecretService, name string, value string) string {
+	t.Helper()
+
+	resp, err := s.Put(context.TODO(), &secretspb.SecretPutRequest{
+		Secret: &secretspb.Secret{Name: name},
+		Value:  []byte(value),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.SecretVersion.Version
+}
+
+func accessSecret(s *DevSecretService, name string, version string) (string, error) {
+	resp, err := s.Access(context.TODO(), &secretspb.SecretAccessRequest{
+		SecretVersion: &secretspb.SecretVersion{
+			Secret:  &secretspb.Secret{Name: name},
+			Version: version,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(resp.Value), nil
+}
+
+func TestEncryptSecrets(t *testing.T) {
+	secretsDir := setTestSecretsDirs(t)
+
+	s := newTestSecretService(t)
+
+	version := putSecret(t, s, "api-key", "super secret")
+
+	for _, fileVersion := range []string{version, "latest"} {
+		content, err := os.ReadFile(filepath.Join(secretsDir, "api-key_"+fileVersion+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !isEncrypted(content) || strings.Contains(string(content), base64.StdEncoding.EncodeToString([]byte("super secret"))) {
+			t.Errorf("expected the %s file to be encrypted, got %s", fileVersion, content)
+		}
+
+		value, err := accessSecret(s, "api-key", fileVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value != "super secret" {
+			t.Errorf("expected the %s version to be %q, got %q", fileVersion, "super secret", value)
+		}
+	}
+}
+
+func TestEncryptSecretsFileName(t *testing.T) {
+	secretsDir := setTestSecretsDirs(t)
+
+	s := newTestSecretService(t)
+
+	first := putSecret(t, s, "api-key", "first")
+	second := putSecret(t, s, "api-key", "second")
+
+	content, err := os.ReadFile(filepath.Join(secretsDir, "api-key_"+first+".txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an encrypted value copied to another version's file shouldn't decrypt
+	err = os.WriteFile(filepath.Join(secretsDir, "api-key_"+second+".txt"), content, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = accessSecret(s, "api-key", second)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected accessing a copied secret to fail, got %v", err)
+	}
+}
+
+func TestDecryptChangedPassphrase(t *testing.T) {
+	setTestSecretsDirs(t)
+
+	version := putSecret(t, newTestSecretService(t), "api-key", "super secret")
+
+	err := os.WriteFile(paths.NitricLocalPassphrasePath(), []byte("a different passphrase"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = accessSecret(newTestSecretService(t), "api-key", version)
+	if err == nil {
+		t.Fatal("expected accessing a secret with a different passphrase to fail")
+	}
+
+	if status.Code(err) != codes.Internal || !strings.Contains(err.Error(), "passphrase may have changed") {
+		t.Errorf("expected an error saying the passphrase may have changed, got %v", err)
+	}
+}
+
+func TestMigrateSecrets(t *testing.T) {
+	secretsDir := setTestSecretsDirs(t)
+
+	now := time.Now().Truncate(time.Second)
+
+	// secrets written before secrets were encrypted, and a temporary file left by an interrupted migration
+	for _, file := range []struct {
+		name    string
+		content string
+		modTime time.Time
+	}{
+		{name: "api-key_v1.txt", content: base64.StdEncoding.EncodeToString([]byte("old")), modTime: now.Add(-2 * time.Hour)},
+		{name: "api-key_v2.txt", content: base64.StdEncoding.EncodeToString([]byte("new")), modTime: now.Add(-time.Hour)},
+		{name: "api-key_latest.txt", content: base64.StdEncoding.EncodeToString([]byte("new")) + ",v2", modTime: now.Add(-time.Hour)},
+		{name: "api-key_v3.txt.tmp", content: "partial", modTime: now},
+	} {
+		path := filepath.Join(secretsDir, file.name)
+
+		err := os.WriteFile(path, []byte(file.content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Chtimes(path, file.modTime, file.modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newTestSecretService(t)
+
+	for _, file := range []struct {
+		name    string
+		modTime time.Time
+	}{
+		{name: "api-key_v1.txt", modTime: now.Add(-2 * time.Hour)},
+		{name: "api-key_v2.txt", modTime: now.Add(-time.Hour)},
+		{name: "api-key_latest.txt", modTime: now.Add(-time.Hour)},
+	} {
+		path := filepath.Join(secretsDir, file.name)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !isEncrypted(content) {
+			t.Errorf("expected %s to be encrypted, got %s", file.name, content)
+		}
+
+		// versions are ordered by their modified time, so migrating shouldn't change it
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !info.ModTime().Equal(file.modTime) {
+			t.Errorf("expected %s to keep its modified time %s, got %s", file.name, file.modTime, info.ModTime())
+		}
+	}
+
+	// the leftover temporary file is neither migrated nor listed
+	content, err := os.ReadFile(filepath.Join(secretsDir, "api-key_v3.txt.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "partial" {
+		t.Errorf("expected the temporary file to be left alone, got %s", content)
+	}
+
+	versions, err := s.List(context.TODO(), "api-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []SecretVersion{
+		{Version: "v2", Value: "new", Latest: true, CreatedAt: now.Add(-time.Hour).Format("2006-01-02 15:04:05")},
+		{Version: "v1", Value: "old", CreatedAt: now.Add(-2 * time.Hour).Format("2006-01-02 15:04:05")},
+	}
+
+	if !cmp.Equal(expected, versions) {
+		t.Error(cmp.Diff(expected, versions))
+	}
+
+	value, err := accessSecret(s, "api-key", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "new" {
+		t.Errorf("expected the latest version to be %q, got %q", "new", value)
+	}
+}
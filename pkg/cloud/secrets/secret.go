@@ -17,8 +17,8 @@
 package secrets
 
 import (
-	"bufio"
 	"context"
+	"crypto/cipher"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -40,6 +40,9 @@ import (
 type DevSecretService struct {
 	secDir string
 	mu     sync.RWMutex
+
+	// encrypts secret values at rest
+	cipher cipher.AEAD
 }
 
 var _ secretspb.SecretManagerServer = (*DevSecretService)(nil)
@@ -54,23 +57,15 @@ func (s *DevSecretService) Put(ctx context.Context, req *secretspb.SecretPutRequ
 		"DevSecretService.Put",
 	)
 
-	versionId := uuid.New().String()
-	// Creates a new file in the form:
-	// DIR/Name_Version.txt
-	file, err := os.Create(s.secretFileName(req.Secret, versionId))
-	if err != nil {
-		return nil, newErr(
-			codes.FailedPrecondition,
-			"error creating secret store",
-			err,
-		)
-	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	versionId := uuid.New().String()
 	sVal := base64.StdEncoding.EncodeToString(req.Value)
 
-	writer := bufio.NewWriter(file)
-
-	_, err = writer.WriteString(sVal)
+	// Creates a new file in the form:
+	// DIR/Name_Version.txt
+	err := s.writeSecretFile(s.secretFileName(req.Secret, versionId), []byte(sVal))
 	if err != nil {
 		return nil, newErr(
 			codes.FailedPrecondition,
@@ -79,33 +74,16 @@ func (s *DevSecretService) Put(ctx context.Context, req *secretspb.SecretPutRequ
 		)
 	}
 
-	writer.Flush()
-	file.Close()
-
 	// Creates a new file as latest
-	latestFile, err := os.Create(s.secretFileName(req.Secret, "latest"))
-	if err != nil {
-		return nil, newErr(
-			codes.FailedPrecondition,
-			"error creating latest secret",
-			err,
-		)
-	}
-
-	latestWriter := bufio.NewWriter(latestFile)
-
-	_, err = latestWriter.WriteString(sVal + "," + versionId)
+	err = s.writeSecretFile(s.secretFileName(req.Secret, "latest"), []byte(sVal+","+versionId))
 	if err != nil {
 		return nil, newErr(
 			codes.FailedPrecondition,
-			"error writing secret value",
+			"error writing latest secret",
 			err,
 		)
 	}
 
-	latestWriter.Flush()
-	latestFile.Close()
-
 	return &secretspb.SecretPutResponse{
 		SecretVersion: &secretspb.SecretVersion{
 			Secret:  req.Secret,
@@ -122,7 +100,9 @@ func (s *DevSecretService) Access(ctx context.Context, req *secretspb.SecretAcce
 		"DevSecretService.Access",
 	)
 
-	content, err := os.ReadFile(s.secretFileName(req.SecretVersion.Secret, req.SecretVersion.Version))
+	fileName := s.secretFileName(req.SecretVersion.Secret, req.SecretVersion.Version)
+
+	content, err := os.ReadFile(fileName)
 	if err != nil {
 		// If the file is missing it's typically because it hasn't been created yet
 		if os.IsNotExist(err) {
@@ -140,6 +120,15 @@ func (s *DevSecretService) Access(ctx context.Context, req *secretspb.SecretAcce
 		)
 	}
 
+	content, err = s.decrypt(filepath.Base(fileName), content)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"error decrypting secret value, the local passphrase may have changed since it was stored",
+			err,
+		)
+	}
+
 	splitContent := strings.Split(string(content), ",")
 	version := req.SecretVersion.Version
 	// check whether a version number is stored in the file, this indicates the 'latest' version file.
@@ -388,7 +377,20 @@ func NewSecretService() (*DevSecretService, error) {
 		}
 	}
 
-	return &DevSecretService{
+	secretsCipher, err := newSecretsCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DevSecretService{
 		secDir: secDir,
-	}, nil
+		cipher: secretsCipher,
+	}
+
+	err = s.migrate()
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting existing secrets: %w", err)
+	}
+
+	return s, nil
 }
@@ -0,0 +1,237 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/asdine/storm"
+
+	"github.com/nitrictech/cli/pkg/cloud/env"
+)
+
+const storageDbName = "storage.db"
+
+// blobMetadata is stored for each blob, as the blob files only hold their contents
+type blobMetadata struct {
+	Key         string `storm:"id"`
+	ContentType string
+	ETag        string
+	// The size and modification time of the file when the metadata was stored, used to detect files changed outside of the storage service
+	Size         int64
+	LastModified time.Time
+}
+
+type BlobInfo struct {
+	Key          string    `json:"key"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	ETag         string    `json:"etag"`
+}
+
+func blobPath(bucket string, key string) string {
+	return filepath.Join(env.LOCAL_BUCKETS_DIR.String(), bucket, key)
+}
+
+// detectContentType guesses the content type of a blob from its key, falling back to sniffing its contents
+func detectContentType(key string, head []byte) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(key)); contentType != "" {
+		return contentType
+	}
+
+	return http.DetectContentType(head)
+}
+
+func quotedETag(sum []byte) string {
+	return fmt.Sprintf("%q", hex.EncodeToString(sum))
+}
+
+func (r *LocalStorageService) storeBlobMetadata(bucket string, key string, contentType string, etag string) error {
+	info, err := os.Stat(blobPath(bucket, key))
+	if err != nil {
+		return err
+	}
+
+	return r.db.From(bucket).Save(&blobMetadata{
+		Key:          key,
+		ContentType:  contentType,
+		ETag:         etag,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	})
+}
+
+func (r *LocalStorageService) deleteBlobMetadata(bucket string, key string) error {
+	err := r.db.From(bucket).DeleteStruct(&blobMetadata{Key: key})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// refreshBlobMetadata computes the metadata of a blob from its file, for blobs added or changed outside of the storage service
+func (r *LocalStorageService) refreshBlobMetadata(bucket string, key string, info os.FileInfo) (*blobMetadata, error) {
+	file, err := os.Open(blobPath(bucket, key))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	hash := md5.New()
+	hash.Write(head[:n])
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &blobMetadata{
+		Key:          key,
+		ContentType:  detectContentType(key, head[:n]),
+		ETag:         quotedETag(hash.Sum(nil)),
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}
+
+	return metadata, r.db.From(bucket).Save(metadata)
+}
+
+// GetBlobInfo returns the metadata of a blob, returns an error satisfying os.IsNotExist if the blob doesn't exist
+func (r *LocalStorageService) GetBlobInfo(bucket string, key string) (*BlobInfo, error) {
+	info, err := os.Stat(blobPath(bucket, key))
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return nil, os.ErrNotExist
+	}
+
+	metadata := &blobMetadata{}
+
+	err = r.db.From(bucket).One("Key", key, metadata)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	if err != nil || metadata.Size != info.Size() || !metadata.LastModified.Equal(info.ModTime()) {
+		metadata, err = r.refreshBlobMetadata(bucket, key, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &BlobInfo{
+		Key:          key,
+		ContentType:  metadata.ContentType,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		ETag:         metadata.ETag,
+	}, nil
+}
+
+type ListBlobsOptions struct {
+	// Only blobs with keys starting with the prefix are listed
+	Prefix string
+	// Keys containing the delimiter after the prefix are grouped into a common prefix, like a folder
+	Delimiter string
+	// Continues a previous listing, from its NextContinuationToken
+	ContinuationToken string
+	// The maximum number of blobs and common prefixes to list, zero lists them all
+	MaxKeys int
+}
+
+type BlobPage struct {
+	Blobs                 []*BlobInfo `json:"files"`
+	CommonPrefixes        []string    `json:"prefixes"`
+	NextContinuationToken string      `json:"nextContinuationToken,omitempty"`
+}
+
+func encodeContinuationToken(startAfter string) string {
+	return base64.URLEncoding.EncodeToString([]byte(startAfter))
+}
+
+func decodeContinuationToken(token string) (string, error) {
+	startAfter, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid continuation token: %w", err)
+	}
+
+	return string(startAfter), nil
+}
+
+// ListBlobPage returns the metadata of the blobs in a bucket in key order, a page at a time
+func (r *LocalStorageService) ListBlobPage(bucket string, opts ListBlobsOptions) (*BlobPage, error) {
+	startAfter, err := decodeContinuationToken(opts.ContinuationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := listBucket(bucket, listOptions{
+		Prefix:     opts.Prefix,
+		Delimiter:  opts.Delimiter,
+		StartAfter: startAfter,
+		MaxKeys:    opts.MaxKeys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blobPage := &BlobPage{
+		Blobs:          []*BlobInfo{},
+		CommonPrefixes: page.CommonPrefixes,
+	}
+
+	if page.IsTruncated {
+		blobPage.NextContinuationToken = encodeContinuationToken(page.NextStartAfter)
+	}
+
+	for _, file := range page.Files {
+		blobInfo, err := r.GetBlobInfo(bucket, file.Key)
+		if err != nil {
+			// the file may have been removed since it was listed
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		blobPage.Blobs = append(blobPage.Blobs, blobInfo)
+	}
+
+	return blobPage, nil
+}
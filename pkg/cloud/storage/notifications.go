@@ -0,0 +1,151 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nitrictech/cli/pkg/eventbus"
+
+	storagepb "github.com/nitrictech/nitric/core/pkg/proto/storage/v1"
+)
+
+const localStorageActionTopic = "local_storage_action"
+
+// ActionState is the outcome of delivering a bucket notification to a listening service
+type ActionState struct {
+	BucketName  string
+	Key         string
+	EventType   storagepb.BlobEventType
+	ServiceName string
+	// The key prefix filter of the listener the notification matched
+	KeyPrefixFilter string
+	Success         bool
+	Error           string
+	Duration        time.Duration
+	// The notification was fired without a change to the bucket, e.g. from the dashboard
+	Synthetic bool
+}
+
+// blobNotification is published to the storage bus for every change to a bucket
+type blobNotification struct {
+	bucket    string
+	key       string
+	eventType storagepb.BlobEventType
+	synthetic bool
+}
+
+type pendingNotification struct {
+	action ActionState
+	sentAt time.Time
+}
+
+// notificationDeliveries tracks the notifications sent to a listener that are awaiting a response
+type notificationDeliveries struct {
+	lock    sync.Mutex
+	pending map[string]*pendingNotification
+}
+
+func notificationTopic(bucket string, eventType storagepb.BlobEventType) string {
+	return fmt.Sprintf("%s:%s", bucket, eventType.String())
+}
+
+func (r *LocalStorageService) SubscribeToAction(fn func(ActionState)) {
+	// ignore the error, it's only returned if the fn param isn't a function
+	_ = r.bus.Subscribe(localStorageActionTopic, fn)
+}
+
+func (r *LocalStorageService) publishAction(action ActionState) {
+	r.bus.Publish(localStorageActionTopic, action)
+}
+
+func (r *LocalStorageService) publishNotification(bucket string, key string, eventType storagepb.BlobEventType, synthetic bool) {
+	eventbus.StorageBus().Publish(notificationTopic(bucket, eventType), &blobNotification{
+		bucket:    bucket,
+		key:       key,
+		eventType: eventType,
+		synthetic: synthetic,
+	})
+}
+
+// TriggerNotification notifies the bucket's listeners of an event without changing the bucket, used to test notification handlers
+func (r *LocalStorageService) TriggerNotification(bucket string, key string, eventType storagepb.BlobEventType) {
+	r.publishNotification(bucket, key, eventType, true)
+}
+
+// add records a notification as sent, returning the id its response will have
+func (n *notificationDeliveries) add(action ActionState) string {
+	id := uuid.New().String()
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.pending[id] = &pendingNotification{
+		action: action,
+		sentAt: time.Now(),
+	}
+
+	return id
+}
+
+// complete removes a notification once it has a response, returning its outcome
+func (n *notificationDeliveries) complete(id string, success bool, err error) (ActionState, bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	pending, ok := n.pending[id]
+	if !ok {
+		return ActionState{}, false
+	}
+
+	delete(n.pending, id)
+
+	action := pending.action
+	action.Success = success
+	action.Duration = time.Since(pending.sentAt)
+
+	if err != nil {
+		action.Error = err.Error()
+	}
+
+	return action, true
+}
+
+// fail completes every notification still awaiting a response, e.g. when the listener disconnects
+func (n *notificationDeliveries) fail(err error) []ActionState {
+	n.lock.Lock()
+	ids := make([]string, 0, len(n.pending))
+
+	for id := range n.pending {
+		ids = append(ids, id)
+	}
+	n.lock.Unlock()
+
+	actions := []ActionState{}
+
+	for _, id := range ids {
+		if action, ok := n.complete(id, false, err); ok {
+			actions = append(actions, action)
+		}
+	}
+
+	return actions
+}
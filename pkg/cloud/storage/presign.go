@@ -0,0 +1,278 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	storagepb "github.com/nitrictech/nitric/core/pkg/proto/storage/v1"
+)
+
+// PresignOperation is the operation a presigned url allows.
+// The runtime API only presigns reads and writes, deletes and heads can be presigned locally, e.g. from the dashboard.
+type PresignOperation string
+
+const (
+	PresignRead   PresignOperation = "READ"
+	PresignWrite  PresignOperation = "WRITE"
+	PresignDelete PresignOperation = "DELETE"
+	PresignHead   PresignOperation = "HEAD"
+)
+
+// maxPresignExpiry matches the longest expiry supported by S3 presigned urls
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// presignPaths are the storage server paths presigned urls are served from, by operation
+var presignPaths = map[PresignOperation]string{
+	PresignRead:   "read",
+	PresignWrite:  "write",
+	PresignDelete: "delete",
+	PresignHead:   "head",
+}
+
+// presignMethods are the only http methods presigned urls can be used with, by operation
+var presignMethods = map[PresignOperation]string{
+	PresignRead:   http.MethodGet,
+	PresignWrite:  http.MethodPut,
+	PresignDelete: http.MethodDelete,
+	PresignHead:   http.MethodHead,
+}
+
+type presignedRequest struct {
+	BucketName string
+	Key        string
+	Operation  PresignOperation
+}
+
+// ParsePresignOperation parses an operation name, e.g. read or HEAD
+func ParsePresignOperation(name string) (PresignOperation, error) {
+	operation := PresignOperation(strings.ToUpper(name))
+
+	if _, ok := presignPaths[operation]; !ok {
+		return "", fmt.Errorf("unknown presign operation %s, must be one of read, write, delete or head", name)
+	}
+
+	return operation, nil
+}
+
+func validatePresignExpiry(expiry time.Duration) error {
+	if expiry < time.Second || expiry > maxPresignExpiry {
+		return fmt.Errorf("presigned url expiry must be between 1 second and %s, got %s", maxPresignExpiry, expiry)
+	}
+
+	return nil
+}
+
+func tokenFromRequest(bucket string, key string, operation PresignOperation, expiry time.Duration) *jwt.Token {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(expiry).Unix(),
+		"request": map[string]string{
+			"bucket": bucket,
+			"key":    key,
+			"op":     string(operation),
+		},
+	})
+}
+
+// requestFromToken validates a presigned url token, returning the error a cloud provider would for invalid or expired urls
+func requestFromToken(token string) (*presignedRequest, *s3Error) {
+	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return getSigningSecret()
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			s3Err := newS3Error(http.StatusForbidden, "AccessDenied", "Request has expired")
+			s3Err.ServerTime = time.Now().UTC().Format(time.RFC3339)
+
+			if parsedToken != nil {
+				if expires, err := parsedToken.Claims.GetExpirationTime(); err == nil && expires != nil {
+					s3Err.Expires = expires.UTC().Format(time.RFC3339)
+				}
+			}
+
+			return nil, s3Err
+		}
+
+		return nil, newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "the presigned url signature is invalid")
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, newS3Error(http.StatusForbidden, "AccessDenied", "could not convert claims to map")
+	}
+
+	requestMap, ok := claims["request"].(map[string]interface{})
+	if !ok {
+		return nil, newS3Error(http.StatusForbidden, "AccessDenied", "could not convert request to map")
+	}
+
+	bucket, _ := requestMap["bucket"].(string)
+	key, _ := requestMap["key"].(string)
+	operation, _ := requestMap["op"].(string)
+
+	return &presignedRequest{
+		BucketName: bucket,
+		Key:        key,
+		Operation:  PresignOperation(operation),
+	}, nil
+}
+
+// PreSignUrlForOperation returns a url that allows a single operation on a blob until it expires
+func (r *LocalStorageService) PreSignUrlForOperation(bucket string, key string, operation PresignOperation, expiry time.Duration) (string, error) {
+	path, ok := presignPaths[operation]
+	if !ok {
+		return "", fmt.Errorf("unknown presign operation %s", operation)
+	}
+
+	err := validatePresignExpiry(expiry)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := getSigningSecret()
+	if err != nil {
+		return "", fmt.Errorf("could not get signing secret: %w", err)
+	}
+
+	tokenString, err := tokenFromRequest(bucket, key, operation, expiry).SignedString(secret)
+	if err != nil {
+		return "", fmt.Errorf("could not sign token: %w", err)
+	}
+
+	// XXX: Do not URL encode keys (path needs to be preserved)
+	// TODO: May need to re-write slashes to a non-escapable character format
+	return fmt.Sprintf("http://localhost:%d/%s/%s", r.storageListener.Addr().(*net.TCPAddr).Port, path, tokenString), nil
+}
+
+func (r *LocalStorageService) PreSignUrl(ctx context.Context, req *storagepb.StoragePreSignUrlRequest) (*storagepb.StoragePreSignUrlResponse, error) {
+	err := r.ensureBucketExists(ctx, req.BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	var operation PresignOperation
+
+	switch req.Operation {
+	case storagepb.StoragePreSignUrlRequest_WRITE:
+		operation = PresignWrite
+	case storagepb.StoragePreSignUrlRequest_READ:
+		operation = PresignRead
+	default:
+		return nil, status.Error(codes.Internal, "error generating presigned url, unknown operation")
+	}
+
+	err = validatePresignExpiry(req.Expiry.AsDuration())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	address, err := r.PreSignUrlForOperation(req.BucketName, req.Key, operation, req.Expiry.AsDuration())
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("error generating presigned url, %v", err))
+	}
+
+	return &storagepb.StoragePreSignUrlResponse{
+		Url: address,
+	}, nil
+}
+
+func presignHandler(storageService *LocalStorageService, operation PresignOperation) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, s3Err := requestFromToken(mux.Vars(r)["token"])
+		if s3Err != nil {
+			writeS3Error(w, r, s3Err)
+			return
+		}
+
+		// urls are signed for a single operation, so using them with any other method fails as it would in the cloud
+		if req.Operation != operation || r.Method != presignMethods[operation] {
+			writeS3Error(w, r, newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", fmt.Sprintf("the presigned url does not allow %s requests", r.Method)))
+			return
+		}
+
+		switch operation {
+		case PresignRead, PresignHead:
+			file, blobInfo, err := storageService.OpenBlob(req.BucketName, req.Key)
+			if err != nil {
+				if os.IsNotExist(err) {
+					writeS3Error(w, r, newS3Error(http.StatusNotFound, "NoSuchKey", "the specified key does not exist"))
+					return
+				}
+
+				writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+
+				return
+			}
+			defer file.Close()
+
+			w.Header().Set("Content-Type", blobInfo.ContentType)
+			w.Header().Set("ETag", blobInfo.ETag)
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(req.Key)}))
+
+			// serve content streams the file and handles HEAD requests, range requests and conditional headers
+			http.ServeContent(w, r, filepath.Base(req.Key), blobInfo.LastModified, file)
+		case PresignWrite:
+			err := storageService.WriteStream(context.Background(), req.BucketName, req.Key, r.Body, r.Header.Get("Content-Type"))
+			if err != nil {
+				writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+
+			_, err = w.Write([]byte("success"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case PresignDelete:
+			_, err := storageService.Delete(context.Background(), &storagepb.StorageDeleteRequest{
+				BucketName: req.BucketName,
+				Key:        req.Key,
+			})
+			// deleting a key that doesn't exist succeeds in S3
+			if err != nil && status.Code(err) != codes.NotFound {
+				writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// registerPresignRoutes adds the presigned url routes to the storage router
+func registerPresignRoutes(router *mux.Router, storageService *LocalStorageService) {
+	for operation, path := range presignPaths {
+		router.HandleFunc("/"+path+"/{token}", presignHandler(storageService, operation))
+	}
+}
@@ -0,0 +1,688 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	storagepb "github.com/nitrictech/nitric/core/pkg/proto/storage/v1"
+)
+
+const (
+	s3Namespace       = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3TimeFormat      = "20060102T150405Z"
+	s3ListTimeFormat  = "2006-01-02T15:04:05.000Z"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3MaxKeys         = 1000
+)
+
+// s3Api serves a subset of the S3 API for local buckets, so standard S3 tooling can be used against them.
+// Only path style requests (http://localhost:port/bucket/key) signed with AWS Signature Version 4 are supported.
+type s3Api struct {
+	storage   *LocalStorageService
+	accessKey string
+	secretKey string
+}
+
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource,omitempty"`
+	// Set for expired presigned urls
+	Expires    string `xml:"Expires,omitempty"`
+	ServerTime string `xml:"ServerTime,omitempty"`
+
+	status int
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3ListBucketResult struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Xmlns                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	KeyCount              int              `xml:"KeyCount"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type s3LocationConstraint struct {
+	XMLName xml.Name `xml:"LocationConstraint"`
+	Xmlns   string   `xml:"xmlns,attr"`
+}
+
+func (e *s3Error) Error() string {
+	return e.Message
+}
+
+func newS3Error(status int, code string, message string) *s3Error {
+	return &s3Error{
+		status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func writeS3Xml(w http.ResponseWriter, status int, body any) {
+	content, err := xml.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(content)
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, s3Err *s3Error) {
+	s3Err.Resource = r.URL.Path
+
+	// HEAD responses have no body, so only the status is returned
+	if r.Method == http.MethodHead {
+		w.WriteHeader(s3Err.status)
+		return
+	}
+
+	writeS3Xml(w, s3Err.status, s3Err)
+}
+
+// isS3Request matches requests signed with AWS Signature Version 4, either in the Authorization header or the query string
+func isS3Request(r *http.Request, rm *mux.RouteMatch) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), s3Algorithm) || r.URL.Query().Get("X-Amz-Algorithm") == s3Algorithm
+}
+
+// s3UriEncode encodes a value the way AWS Signature Version 4 canonical requests expect
+func s3UriEncode(value string, encodeSlash bool) string {
+	encoded := strings.Builder{}
+
+	for _, c := range []byte(value) {
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			encoded.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&encoded, "%%%02X", c)
+	}
+
+	return encoded.String()
+}
+
+func hmacSha256(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+
+	return mac.Sum(nil)
+}
+
+func sha256Hex(value []byte) string {
+	sum := sha256.Sum256(value)
+
+	return hex.EncodeToString(sum[:])
+}
+
+type s3Signature struct {
+	credential    string
+	signedHeaders []string
+	signature     string
+	amzDate       string
+	payloadHash   string
+	expires       time.Duration
+}
+
+func parseS3Signature(r *http.Request) (*s3Signature, *s3Error) {
+	query := r.URL.Query()
+
+	if query.Get("X-Amz-Algorithm") == s3Algorithm {
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil {
+			return nil, newS3Error(http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Expires must be a number of seconds")
+		}
+
+		return &s3Signature{
+			credential:    query.Get("X-Amz-Credential"),
+			signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+			signature:     query.Get("X-Amz-Signature"),
+			amzDate:       query.Get("X-Amz-Date"),
+			payloadHash:   s3UnsignedPayload,
+			expires:       time.Duration(expires) * time.Second,
+		}, nil
+	}
+
+	signature := &s3Signature{
+		amzDate:     r.Header.Get("X-Amz-Date"),
+		payloadHash: r.Header.Get("X-Amz-Content-Sha256"),
+	}
+
+	if signature.payloadHash == "" {
+		signature.payloadHash = s3UnsignedPayload
+	}
+
+	authorization := strings.TrimPrefix(r.Header.Get("Authorization"), s3Algorithm)
+
+	for _, part := range strings.Split(authorization, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		switch key {
+		case "Credential":
+			signature.credential = value
+		case "SignedHeaders":
+			signature.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			signature.signature = value
+		}
+	}
+
+	if signature.credential == "" || signature.signature == "" || len(signature.signedHeaders) == 0 {
+		return nil, newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", "the authorization header is malformed")
+	}
+
+	return signature, nil
+}
+
+func canonicalS3Query(r *http.Request) string {
+	query := r.URL.Query()
+	params := []string{}
+
+	for key, values := range query {
+		if key == "X-Amz-Signature" {
+			continue
+		}
+
+		for _, value := range values {
+			params = append(params, s3UriEncode(key, true)+"="+s3UriEncode(value, true))
+		}
+	}
+
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+func canonicalS3HeaderValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		// the content length is removed from the headers by the http server
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	values := []string{}
+	for _, value := range r.Header.Values(name) {
+		values = append(values, strings.Join(strings.Fields(value), " "))
+	}
+
+	return strings.Join(values, ",")
+}
+
+// authenticate verifies a request is signed with the local storage credentials using AWS Signature Version 4
+func (s *s3Api) authenticate(r *http.Request) (*s3Signature, *s3Error) {
+	signature, s3Err := parseS3Signature(r)
+	if s3Err != nil {
+		return nil, s3Err
+	}
+
+	// credential format: <access key>/<date>/<region>/<service>/aws4_request
+	credentialParts := strings.Split(signature.credential, "/")
+	if len(credentialParts) != 5 {
+		return nil, newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", "the credential is malformed")
+	}
+
+	if credentialParts[0] != s.accessKey {
+		return nil, newS3Error(http.StatusForbidden, "InvalidAccessKeyId", "the access key id does not match the local storage access key")
+	}
+
+	if signature.expires > 0 {
+		signedAt, err := time.Parse(s3TimeFormat, signature.amzDate)
+		if err != nil {
+			return nil, newS3Error(http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Date is malformed")
+		}
+
+		if time.Now().After(signedAt.Add(signature.expires)) {
+			return nil, newS3Error(http.StatusForbidden, "AccessDenied", "Request has expired")
+		}
+	}
+
+	canonicalHeaders := strings.Builder{}
+	for _, name := range signature.signedHeaders {
+		canonicalHeaders.WriteString(name + ":" + canonicalS3HeaderValue(r, name) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		s3UriEncode(r.URL.Path, false),
+		canonicalS3Query(r),
+		canonicalHeaders.String(),
+		strings.Join(signature.signedHeaders, ";"),
+		signature.payloadHash,
+	}, "\n")
+
+	scope := strings.Join(credentialParts[1:], "/")
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		signature.amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+s.secretKey), credentialParts[1])
+	for _, part := range credentialParts[2:] {
+		signingKey = hmacSha256(signingKey, part)
+	}
+
+	expected := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	if !hmac.Equal([]byte(expected), []byte(signature.signature)) {
+		return nil, newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "the request signature does not match the signature calculated with the local storage credentials")
+	}
+
+	return signature, nil
+}
+
+// awsChunkedReader decodes a body sent with aws-chunked content encoding as it is read.
+// Only the seed signature of the request is verified, by authenticate. The signatures of each chunk and any trailing checksums are skipped, not verified.
+type awsChunkedReader struct {
+	reader *bufio.Reader
+	// the unread bytes of the current chunk
+	remaining int64
+	started   bool
+	done      bool
+}
+
+func newAwsChunkedReader(body io.Reader) *awsChunkedReader {
+	return &awsChunkedReader{reader: bufio.NewReader(body)}
+}
+
+func (c *awsChunkedReader) nextChunk() error {
+	// each chunk is terminated by a CRLF
+	if c.started {
+		_, err := c.reader.Discard(2)
+		if err != nil {
+			return fmt.Errorf("invalid chunk: %w", err)
+		}
+	}
+
+	c.started = true
+
+	header, err := c.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("invalid chunk header: %w", err)
+	}
+
+	sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil {
+		return fmt.Errorf("invalid chunk size %q: %w", sizeHex, err)
+	}
+
+	c.remaining = size
+	c.done = size == 0
+
+	return nil
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	if c.remaining == 0 && !c.done {
+		err := c.nextChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if c.done {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err := c.reader.Read(p)
+	c.remaining -= int64(n)
+
+	if errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("invalid chunk: %w", io.ErrUnexpectedEOF)
+	}
+
+	return n, err
+}
+
+// s3Body streams an uploaded object, verifying the payload hash once the body has been read.
+// Records why the body was rejected, so the upload can fail with the matching S3 error.
+type s3Body struct {
+	reader io.Reader
+	// nil for unsigned payloads
+	hash        hash.Hash
+	payloadHash string
+
+	err *s3Error
+}
+
+func newS3Body(r *http.Request, signature *s3Signature) *s3Body {
+	if strings.HasPrefix(signature.payloadHash, "STREAMING-") || strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		return &s3Body{reader: newAwsChunkedReader(r.Body)}
+	}
+
+	body := &s3Body{reader: r.Body}
+
+	if signature.payloadHash != s3UnsignedPayload {
+		body.hash = sha256.New()
+		body.payloadHash = signature.payloadHash
+	}
+
+	return body
+}
+
+func (b *s3Body) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+
+	if b.hash != nil {
+		b.hash.Write(p[:n])
+	}
+
+	if errors.Is(err, io.EOF) {
+		if b.hash != nil && hex.EncodeToString(b.hash.Sum(nil)) != b.payloadHash {
+			b.err = newS3Error(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "the provided x-amz-content-sha256 header does not match the request body")
+			return n, b.err
+		}
+
+		return n, io.EOF
+	}
+
+	if err != nil {
+		b.err = newS3Error(http.StatusBadRequest, "IncompleteBody", err.Error())
+	}
+
+	return n, err
+}
+
+// validS3Key rejects keys that would resolve outside of the bucket directory
+func validS3Key(key string) bool {
+	return !slices.Contains(strings.Split(key, "/"), "..")
+}
+
+func (s *s3Api) handleBucket(w http.ResponseWriter, r *http.Request) {
+	_, s3Err := s.authenticate(r)
+	if s3Err != nil {
+		writeS3Error(w, r, s3Err)
+		return
+	}
+
+	bucket := mux.Vars(r)["bucket"]
+
+	exists, err := s.storage.bucketExists(bucket)
+	if err != nil {
+		writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+		return
+	}
+
+	if !exists {
+		writeS3Error(w, r, newS3Error(http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		if r.URL.Query().Has("location") {
+			writeS3Xml(w, http.StatusOK, s3LocationConstraint{Xmlns: s3Namespace})
+			return
+		}
+
+		if r.URL.Query().Get("list-type") != "2" {
+			writeS3Error(w, r, newS3Error(http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 is supported by the local storage service"))
+			return
+		}
+
+		s.listObjects(w, r, bucket)
+	default:
+		writeS3Error(w, r, newS3Error(http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("%s bucket requests are not supported by the local storage service", r.Method)))
+	}
+}
+
+func (s *s3Api) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+
+	maxKeys := s3MaxKeys
+
+	if maxKeysParam := query.Get("max-keys"); maxKeysParam != "" {
+		var err error
+
+		maxKeys, err = strconv.Atoi(maxKeysParam)
+		if err != nil || maxKeys < 0 {
+			writeS3Error(w, r, newS3Error(http.StatusBadRequest, "InvalidArgument", "max-keys must be a positive number"))
+			return
+		}
+
+		maxKeys = min(maxKeys, s3MaxKeys)
+	}
+
+	startAfter := query.Get("start-after")
+
+	if token := query.Get("continuation-token"); token != "" {
+		var err error
+
+		startAfter, err = decodeContinuationToken(token)
+		if err != nil {
+			writeS3Error(w, r, newS3Error(http.StatusBadRequest, "InvalidArgument", "the continuation token is invalid"))
+			return
+		}
+	}
+
+	page, err := listBucket(bucket, listOptions{
+		Prefix:     query.Get("prefix"),
+		Delimiter:  query.Get("delimiter"),
+		StartAfter: startAfter,
+		MaxKeys:    maxKeys,
+	})
+	if err != nil {
+		writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+		return
+	}
+
+	result := s3ListBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            query.Get("prefix"),
+		Delimiter:         query.Get("delimiter"),
+		StartAfter:        query.Get("start-after"),
+		ContinuationToken: query.Get("continuation-token"),
+		MaxKeys:           maxKeys,
+		KeyCount:          len(page.Files) + len(page.CommonPrefixes),
+		IsTruncated:       page.IsTruncated,
+		Contents:          []s3Object{},
+		CommonPrefixes:    []s3CommonPrefix{},
+	}
+
+	if page.IsTruncated {
+		result.NextContinuationToken = encodeContinuationToken(page.NextStartAfter)
+	}
+
+	for _, file := range page.Files {
+		blobInfo, err := s.storage.GetBlobInfo(bucket, file.Key)
+		if err != nil {
+			writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+			return
+		}
+
+		result.Contents = append(result.Contents, s3Object{
+			Key:          file.Key,
+			LastModified: blobInfo.LastModified.UTC().Format(s3ListTimeFormat),
+			ETag:         blobInfo.ETag,
+			Size:         blobInfo.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+
+	for _, prefix := range page.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: prefix})
+	}
+
+	writeS3Xml(w, http.StatusOK, result)
+}
+
+func (s *s3Api) handleObject(w http.ResponseWriter, r *http.Request) {
+	signature, s3Err := s.authenticate(r)
+	if s3Err != nil {
+		writeS3Error(w, r, s3Err)
+		return
+	}
+
+	bucket := mux.Vars(r)["bucket"]
+	key := mux.Vars(r)["key"]
+
+	if !validS3Key(key) {
+		writeS3Error(w, r, newS3Error(http.StatusBadRequest, "InvalidArgument", "object keys cannot contain .. segments"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.getObject(w, r, bucket, key)
+	case http.MethodPut:
+		s.putObject(w, r, signature, bucket, key)
+	case http.MethodDelete:
+		s.deleteObject(w, r, bucket, key)
+	default:
+		writeS3Error(w, r, newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("%s object requests are not supported", r.Method)))
+	}
+}
+
+func (s *s3Api) getObject(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	file, blobInfo, err := s.storage.OpenBlob(bucket, key)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeS3Error(w, r, newS3Error(http.StatusNotFound, "NoSuchKey", "the specified key does not exist"))
+			return
+		}
+
+		writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", blobInfo.ContentType)
+	w.Header().Set("ETag", blobInfo.ETag)
+
+	// serve content handles HEAD requests, range requests and conditional headers
+	http.ServeContent(w, r, filepath.Base(key), blobInfo.LastModified, file)
+}
+
+func (s *s3Api) putObject(w http.ResponseWriter, r *http.Request, signature *s3Signature, bucket string, key string) {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeS3Error(w, r, newS3Error(http.StatusNotImplemented, "NotImplemented", "CopyObject is not supported by the local storage service"))
+		return
+	}
+
+	body := newS3Body(r, signature)
+
+	err := s.storage.WriteStream(r.Context(), bucket, key, body, r.Header.Get("Content-Type"))
+	if err != nil {
+		if body.err != nil {
+			writeS3Error(w, r, body.err)
+			return
+		}
+
+		writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+
+		return
+	}
+
+	blobInfo, err := s.storage.GetBlobInfo(bucket, key)
+	if err != nil {
+		writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+		return
+	}
+
+	w.Header().Set("ETag", blobInfo.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *s3Api) deleteObject(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	_, err := s.storage.Delete(r.Context(), &storagepb.StorageDeleteRequest{
+		BucketName: bucket,
+		Key:        key,
+	})
+	// deleting a key that doesn't exist succeeds in S3
+	if err != nil && status.Code(err) != codes.NotFound {
+		writeS3Error(w, r, newS3Error(http.StatusInternalServerError, "InternalError", err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registerS3Routes adds the S3 compatible API to the storage router, the routes only match requests signed for S3
+func registerS3Routes(router *mux.Router, storageService *LocalStorageService, opts StorageOptions) {
+	api := &s3Api{
+		storage:   storageService,
+		accessKey: opts.AccessKey,
+		secretKey: opts.SecretKey,
+	}
+
+	s3Router := router.MatcherFunc(isS3Request).Subrouter()
+
+	s3Router.HandleFunc("/{bucket}", api.handleBucket)
+	s3Router.HandleFunc("/{bucket}/", api.handleBucket)
+	s3Router.HandleFunc("/{bucket}/{key:.+}", api.handleObject)
+}
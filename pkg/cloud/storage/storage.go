@@ -17,29 +17,31 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/asaskevich/EventBus"
+	"github.com/asdine/storm"
 	"github.com/gorilla/mux"
+	"go.etcd.io/bbolt"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-
-	jwt "github.com/golang-jwt/jwt/v5"
 
 	"github.com/nitrictech/cli/pkg/cloud/env"
 	"github.com/nitrictech/cli/pkg/eventbus"
 	"github.com/nitrictech/cli/pkg/grpcx"
+	"github.com/nitrictech/cli/pkg/project/localconfig"
 
 	grpc_errors "github.com/nitrictech/nitric/core/pkg/grpc/errors"
 	"github.com/nitrictech/nitric/core/pkg/logger"
@@ -85,6 +87,15 @@ type LocalStorageService struct {
 
 	storageListener net.Listener
 
+	// holds blob metadata
+	db *storm.DB
+
+	localConfig localconfig.LocalConfiguration
+
+	// declaredBuckets are the buckets declared by the running services, which exist before anything is written to them
+	declaredBuckets     []BucketName
+	declaredBucketsLock sync.RWMutex
+
 	bus EventBus.Bus
 }
 
@@ -183,19 +194,53 @@ func (r *LocalStorageService) Listen(stream storagepb.StorageListener_ListenServ
 		return err
 	}
 
-	bucketName := firstRequest.GetRegistrationRequest().GetBucketName()
-	listenEvtType := firstRequest.GetRegistrationRequest().GetBlobEventType().String()
+	registration := firstRequest.GetRegistrationRequest()
+	listenTopicName := notificationTopic(registration.GetBucketName(), registration.GetBlobEventType())
+
+	r.registerListener(serviceName, registration)
+	defer r.unregisterListener(serviceName, registration)
+
+	deliveries := &notificationDeliveries{pending: map[string]*pendingNotification{}}
+
+	// notifications are delivered concurrently, but a stream only supports one sender at a time
+	sendLock := sync.Mutex{}
 
-	listenTopicName := fmt.Sprintf("%s:%s", bucketName, listenEvtType)
+	fun := func(notification *blobNotification) {
+		if !strings.HasPrefix(notification.key, registration.KeyPrefixFilter) {
+			return
+		}
+
+		id := deliveries.add(ActionState{
+			BucketName:      notification.bucket,
+			Key:             notification.key,
+			EventType:       notification.eventType,
+			ServiceName:     serviceName,
+			KeyPrefixFilter: registration.KeyPrefixFilter,
+			Synthetic:       notification.synthetic,
+		})
+
+		sendLock.Lock()
+		err := stream.Send(&storagepb.ServerMessage{
+			Id: id,
+			Content: &storagepb.ServerMessage_BlobEventRequest{
+				BlobEventRequest: &storagepb.BlobEventRequest{
+					BucketName: notification.bucket,
+					Event: &storagepb.BlobEventRequest_BlobEvent{
+						BlobEvent: &storagepb.BlobEvent{
+							Key:  notification.key,
+							Type: notification.eventType,
+						},
+					},
+				},
+			},
+		})
+		sendLock.Unlock()
 
-	r.registerListener(serviceName, firstRequest.GetRegistrationRequest())
-	defer r.unregisterListener(serviceName, firstRequest.GetRegistrationRequest())
+		if err != nil {
+			fmt.Println("problem sending the event")
 
-	fun := func(req *storagepb.ServerMessage) {
-		if strings.HasPrefix(req.GetBlobEventRequest().GetBlobEvent().Key, firstRequest.GetRegistrationRequest().KeyPrefixFilter) {
-			err := stream.Send(req)
-			if err != nil {
-				fmt.Println("problem sending the event")
+			if action, ok := deliveries.complete(id, false, err); ok {
+				r.publishAction(action)
 			}
 		}
 	}
@@ -211,10 +256,20 @@ func (r *LocalStorageService) Listen(stream storagepb.StorageListener_ListenServ
 
 	// block here...
 	for {
-		_, err := stream.Recv()
+		msg, err := stream.Recv()
 		if err != nil {
+			for _, action := range deliveries.fail(fmt.Errorf("listener disconnected before handling the notification")) {
+				r.publishAction(action)
+			}
+
 			return err
-		} // responses are not logged since the buckets can be viewed to review the state
+		}
+
+		if resp := msg.GetBlobEventResponse(); resp != nil {
+			if action, ok := deliveries.complete(msg.Id, resp.Success, nil); ok {
+				r.publishAction(action)
+			}
+		}
 	}
 }
 
@@ -222,20 +277,38 @@ func (r *LocalStorageService) ensureBucketExists(ctx context.Context, bucket str
 	return os.MkdirAll(filepath.Join(env.LOCAL_BUCKETS_DIR.String(), bucket), os.ModePerm)
 }
 
+// SetDeclaredBuckets updates the buckets declared by the running services
+func (r *LocalStorageService) SetDeclaredBuckets(buckets []BucketName) {
+	r.declaredBucketsLock.Lock()
+	defer r.declaredBucketsLock.Unlock()
+
+	r.declaredBuckets = buckets
+}
+
+// bucketExists returns true if the bucket is declared or has been created in the local buckets directory
+func (r *LocalStorageService) bucketExists(bucket string) (bool, error) {
+	r.declaredBucketsLock.RLock()
+	declared := slices.Contains(r.declaredBuckets, bucket)
+	r.declaredBucketsLock.RUnlock()
+
+	if declared {
+		return true, nil
+	}
+
+	info, err := os.Stat(filepath.Join(env.LOCAL_BUCKETS_DIR.String(), bucket))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return info.IsDir(), nil
+}
+
 func (r *LocalStorageService) triggerBucketNotifications(ctx context.Context, bucket string, key string, eventType storagepb.BlobEventType) {
-	eventbus.StorageBus().Publish(fmt.Sprintf("%s:%s", bucket, eventType.String()), &storagepb.ServerMessage{
-		Content: &storagepb.ServerMessage_BlobEventRequest{
-			BlobEventRequest: &storagepb.BlobEventRequest{
-				BucketName: bucket,
-				Event: &storagepb.BlobEventRequest_BlobEvent{
-					BlobEvent: &storagepb.BlobEvent{
-						Key:  key,
-						Type: eventType,
-					},
-				},
-			},
-		},
-	})
+	r.publishNotification(bucket, key, eventType, false)
 }
 
 // TODO: If we move declare here, we can stop attempting to lazily create buckets in the storage service
@@ -301,7 +374,16 @@ func (r *LocalStorageService) Exists(ctx context.Context, req *storagepb.Storage
 }
 
 func (r *LocalStorageService) Write(ctx context.Context, req *storagepb.StorageWriteRequest) (*storagepb.StorageWriteResponse, error) {
-	newErr := grpc_errors.ErrorsWithScope("DevStorageService.Write")
+	err := r.WriteStream(ctx, req.BucketName, req.Key, bytes.NewReader(req.Body), "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &storagepb.StorageWriteResponse{}, nil
+}
+
+func (r *LocalStorageService) Delete(ctx context.Context, req *storagepb.StorageDeleteRequest) (*storagepb.StorageDeleteResponse, error) {
+	newErr := grpc_errors.ErrorsWithScope("DevStorageService.Delete")
 
 	err := r.ensureBucketExists(ctx, req.BucketName)
 	if err != nil {
@@ -312,34 +394,42 @@ func (r *LocalStorageService) Write(ctx context.Context, req *storagepb.StorageW
 		)
 	}
 
+	err = r.archiveBlob(req.BucketName, req.Key, true)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"could not keep previous version",
+			err,
+		)
+	}
+
 	fileRef := filepath.Join(env.LOCAL_BUCKETS_DIR.String(), req.BucketName, req.Key)
 
-	// Ensure the directory structure exists
-	err = os.MkdirAll(filepath.Dir(fileRef), os.ModePerm)
+	err = os.Remove(fileRef)
 	if err != nil {
 		return nil, newErr(
-			codes.Internal,
-			"could not create bucket",
+			codes.NotFound,
+			"could not delete file",
 			err,
 		)
 	}
 
-	err = os.WriteFile(fileRef, req.Body, os.ModePerm)
+	err = r.deleteBlobMetadata(req.BucketName, req.Key)
 	if err != nil {
 		return nil, newErr(
 			codes.Internal,
-			"could not write to file",
+			"could not delete file metadata",
 			err,
 		)
 	}
 
-	go r.triggerBucketNotifications(ctx, req.BucketName, req.Key, storagepb.BlobEventType_Created)
+	go r.triggerBucketNotifications(ctx, req.BucketName, req.Key, storagepb.BlobEventType_Deleted)
 
-	return &storagepb.StorageWriteResponse{}, nil
+	return &storagepb.StorageDeleteResponse{}, nil
 }
 
-func (r *LocalStorageService) Delete(ctx context.Context, req *storagepb.StorageDeleteRequest) (*storagepb.StorageDeleteResponse, error) {
-	newErr := grpc_errors.ErrorsWithScope("DevStorageService.Delete")
+func (r *LocalStorageService) ListBlobs(ctx context.Context, req *storagepb.StorageListBlobsRequest) (*storagepb.StorageListBlobsResponse, error) {
+	newErr := grpc_errors.ErrorsWithScope("DevStorageService.ListBlobs")
 
 	err := r.ensureBucketExists(ctx, req.BucketName)
 	if err != nil {
@@ -350,152 +440,181 @@ func (r *LocalStorageService) Delete(ctx context.Context, req *storagepb.Storage
 		)
 	}
 
-	fileRef := filepath.Join(env.LOCAL_BUCKETS_DIR.String(), req.BucketName, req.Key)
-
-	err = os.Remove(fileRef)
+	page, err := listBucket(req.BucketName, listOptions{
+		Prefix: req.Prefix,
+	})
 	if err != nil {
 		return nil, newErr(
-			codes.NotFound,
-			"could not delete file",
+			codes.Internal,
+			"could not list blobs",
 			err,
 		)
 	}
 
-	go r.triggerBucketNotifications(ctx, req.BucketName, req.Key, storagepb.BlobEventType_Deleted)
+	blobs := []*storagepb.Blob{}
 
-	return &storagepb.StorageDeleteResponse{}, nil
+	for _, file := range page.Files {
+		blobs = append(blobs, &storagepb.Blob{
+			Key: file.Key,
+		})
+	}
+
+	return &storagepb.StorageListBlobsResponse{
+		Blobs: blobs,
+	}, nil
 }
 
-func (r *LocalStorageService) ListBlobs(ctx context.Context, req *storagepb.StorageListBlobsRequest) (*storagepb.StorageListBlobsResponse, error) {
-	newErr := grpc_errors.ErrorsWithScope("DevStorageService.ListBlobs")
+type listOptions struct {
+	// Only keys starting with the prefix are listed
+	Prefix string
+	// Keys containing the delimiter after the prefix are grouped into a common prefix, like a folder
+	Delimiter string
+	// Only keys (or common prefixes) after this value are listed
+	StartAfter string
+	// The maximum number of keys and common prefixes to list, zero lists them all
+	MaxKeys int
+}
 
-	err := r.ensureBucketExists(ctx, req.BucketName)
-	if err != nil {
-		return nil, newErr(
-			codes.FailedPrecondition,
-			"failed to get bucket",
-			err,
-		)
+type blobFile struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type listPage struct {
+	Files          []blobFile
+	CommonPrefixes []string
+	IsTruncated    bool
+	// The last key or common prefix in the page, used to continue listing
+	NextStartAfter string
+}
+
+// listBucket lists the files in a bucket in key order
+func listBucket(bucket string, opts listOptions) (*listPage, error) {
+	localBucket := filepath.Join(env.LOCAL_BUCKETS_DIR.String(), bucket)
+
+	// only walk the folder the prefix is in, rather than the whole bucket
+	walkRoot := localBucket
+	if idx := strings.LastIndex(opts.Prefix, "/"); idx >= 0 {
+		walkRoot = filepath.Join(localBucket, filepath.FromSlash(opts.Prefix[:idx]))
 	}
 
-	blobs := []*storagepb.Blob{}
+	files := []blobFile{}
 
-	localBucket := filepath.Join(env.LOCAL_BUCKETS_DIR.String(), req.BucketName)
+	err := filepath.Walk(walkRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// a prefix folder that doesn't exist has no files
+			if path == walkRoot && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
 
-	err = filepath.Walk(localBucket, func(path string, info os.FileInfo, err error) error {
+			return err
+		}
+
+		relPath, err := filepath.Rel(localBucket, path)
 		if err != nil {
 			return err
 		}
 
-		if !info.IsDir() {
-			relPath, err := filepath.Rel(localBucket, path)
-			if err != nil {
-				return err
+		key := filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			// skip folders that can't contain keys with the prefix
+			if path != walkRoot && !strings.HasPrefix(key+"/", opts.Prefix) && !strings.HasPrefix(opts.Prefix, key+"/") {
+				return filepath.SkipDir
 			}
 
-			blobs = append(blobs, &storagepb.Blob{
-				Key: filepath.ToSlash(relPath),
+			return nil
+		}
+
+		if strings.HasPrefix(key, opts.Prefix) {
+			files = append(files, blobFile{
+				Key:          key,
+				Size:         info.Size(),
+				LastModified: info.ModTime(),
 			})
 		}
 
 		return nil
 	})
 	if err != nil {
-		return nil, newErr(
-			codes.Internal,
-			"could not list blobs",
-			err,
-		)
+		return nil, err
 	}
 
-	return &storagepb.StorageListBlobsResponse{
-		Blobs: blobs,
-	}, nil
-}
-
-func tokenFromRequest(req *storagepb.StoragePreSignUrlRequest) *jwt.Token {
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"exp": time.Now().Add(req.Expiry.AsDuration()).Unix(),
-		"request": map[string]string{
-			"bucket": req.BucketName,
-			"key":    req.Key,
-			"op":     req.Operation.String(),
-		},
+	// walk orders keys by path segment, listings are ordered by the full key
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Key < files[j].Key
 	})
-}
 
-func requestFromToken(token string) (*storagepb.StoragePreSignUrlRequest, error) {
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		return getSigningSecret()
-	}, jwt.WithExpirationRequired())
-	if err != nil {
-		return nil, err
+	page := &listPage{
+		Files:          []blobFile{},
+		CommonPrefixes: []string{},
 	}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, fmt.Errorf("could not convert claims to map")
-	}
+	count := 0
 
-	requestMap, ok := claims["request"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("could not convert request to map")
-	}
+	for _, file := range files {
+		if opts.StartAfter != "" && file.Key <= opts.StartAfter {
+			continue
+		}
 
-	return &storagepb.StoragePreSignUrlRequest{
-		BucketName: requestMap["bucket"].(string),
-		Key:        requestMap["key"].(string),
-		Operation:  storagepb.StoragePreSignUrlRequest_Operation(storagepb.StoragePreSignUrlRequest_Operation_value[requestMap["op"].(string)]),
-	}, nil
-}
+		commonPrefix := ""
 
-func (r *LocalStorageService) PreSignUrl(ctx context.Context, req *storagepb.StoragePreSignUrlRequest) (*storagepb.StoragePreSignUrlResponse, error) {
-	err := r.ensureBucketExists(ctx, req.BucketName)
-	if err != nil {
-		return nil, err
-	}
+		if opts.Delimiter != "" {
+			if idx := strings.Index(file.Key[len(opts.Prefix):], opts.Delimiter); idx >= 0 {
+				commonPrefix = file.Key[:len(opts.Prefix)+idx+len(opts.Delimiter)]
+			}
+		}
 
-	var address string = ""
+		// keys in a common prefix that has already been listed, either in this page or a previous one
+		if commonPrefix != "" && (commonPrefix == opts.StartAfter || commonPrefix == page.NextStartAfter) {
+			continue
+		}
 
-	token := tokenFromRequest(req)
+		if opts.MaxKeys > 0 && count == opts.MaxKeys {
+			page.IsTruncated = true
+			break
+		}
 
-	secret, err := getSigningSecret()
-	if err != nil {
-		return nil, status.Error(codes.Internal, "error generating presigned url, could not get signing secret")
-	}
+		if commonPrefix != "" {
+			page.CommonPrefixes = append(page.CommonPrefixes, commonPrefix)
+			page.NextStartAfter = commonPrefix
+		} else {
+			page.Files = append(page.Files, file)
+			page.NextStartAfter = file.Key
+		}
 
-	tokenString, err := token.SignedString(secret)
-	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Sprintf("error generating presigned url, could not sign token: %v", err))
+		count++
 	}
 
-	// XXX: Do not URL encode keys (path needs to be preserved)
-	// TODO: May need to re-write slashes to a non-escapable character format
-	switch req.Operation {
-	case storagepb.StoragePreSignUrlRequest_WRITE:
-		address = fmt.Sprintf("http://localhost:%d/write/%s", r.storageListener.Addr().(*net.TCPAddr).Port, tokenString)
-	case storagepb.StoragePreSignUrlRequest_READ:
-		address = fmt.Sprintf("http://localhost:%d/read/%s", r.storageListener.Addr().(*net.TCPAddr).Port, tokenString)
+	if !page.IsTruncated {
+		page.NextStartAfter = ""
 	}
 
-	if address == "" {
-		return nil, status.Error(codes.Internal, "error generating presigned url, unknown operation")
-	}
+	return page, nil
+}
 
-	return &storagepb.StoragePreSignUrlResponse{
-		Url: address,
-	}, nil
+// GetStorageEndpoint returns the address of the storage server, which serves presigned urls and the S3 compatible API
+func (r *LocalStorageService) GetStorageEndpoint() string {
+	return fmt.Sprintf("http://localhost:%d", r.storageListener.Addr().(*net.TCPAddr).Port)
+}
+
+func (r *LocalStorageService) Close() error {
+	return r.db.Close()
 }
 
 type StorageOptions struct {
+	// The credentials S3 compatible clients must sign requests with
 	AccessKey string
 	SecretKey string
+
+	LocalConfig localconfig.LocalConfiguration
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS, PUT")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS, PUT, DELETE")
 		w.Header().Set("Access-Control-Allow-Headers", "*")
 
 		if r.Method == http.MethodOptions {
@@ -510,9 +629,23 @@ func corsMiddleware(next http.Handler) http.Handler {
 func NewLocalStorageService(opts StorageOptions) (*LocalStorageService, error) {
 	var err error
 
+	storageDir := env.LOCAL_STORAGE_DIR.String()
+
+	err = os.MkdirAll(storageDir, 0o777)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := storm.Open(filepath.Join(storageDir, storageDbName), storm.BoltOptions(0o600, &bbolt.Options{Timeout: 1 * time.Second}))
+	if err != nil {
+		return nil, err
+	}
+
 	storageService := &LocalStorageService{
-		listeners: map[string]map[string]int{},
-		bus:       EventBus.New(),
+		listeners:   map[string]map[string]int{},
+		db:          db,
+		bus:         EventBus.New(),
+		localConfig: opts.LocalConfig,
 	}
 
 	storageService.storageListener, err = net.Listen("tcp", ":0")
@@ -523,90 +656,10 @@ func NewLocalStorageService(opts StorageOptions) (*LocalStorageService, error) {
 	router := mux.NewRouter()
 	router.Use(corsMiddleware)
 
-	router.HandleFunc("/read/{token}", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "invalid method", http.StatusBadRequest)
-			return
-		}
-
-		vars := mux.Vars(r)
-		token := vars["token"]
-
-		req, err := requestFromToken(token)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		if req.Operation != storagepb.StoragePreSignUrlRequest_READ {
-			http.Error(w, "invalid operation", http.StatusBadRequest)
-			return
-		}
-
-		resp, err := storageService.Read(context.Background(), &storagepb.StorageReadRequest{
-			BucketName: req.BucketName,
-			Key:        req.Key,
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(req.Key))
-		w.WriteHeader(http.StatusOK)
-
-		_, err = w.Write(resp.Body)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	})
-
-	router.HandleFunc("/write/{token}", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPut {
-			http.Error(w, "invalid method", http.StatusBadRequest)
-			return
-		}
-
-		vars := mux.Vars(r)
-		token := vars["token"]
+	// registered first, so signed S3 requests for buckets named read, write, head or delete aren't treated as presigned urls
+	registerS3Routes(router, storageService, opts)
 
-		req, err := requestFromToken(token)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		if req.Operation != storagepb.StoragePreSignUrlRequest_WRITE {
-			http.Error(w, "invalid operation", http.StatusBadRequest)
-			return
-		}
-
-		content, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		_, err = storageService.Write(context.Background(), &storagepb.StorageWriteRequest{
-			BucketName: req.BucketName,
-			Key:        req.Key,
-			Body:       content,
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		w.WriteHeader(http.StatusOK)
-
-		_, err = w.Write([]byte("success"))
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	})
+	registerPresignRoutes(router, storageService)
 
 	go func() {
 		err := http.Serve(storageService.storageListener, router)
@@ -0,0 +1,325 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/nitrictech/cli/pkg/cloud/env"
+	coreenv "github.com/nitrictech/nitric/core/pkg/env"
+)
+
+const testBucket = "test-bucket"
+
+var testBucketKeys = []string{
+	"a.txt",
+	"b/1.txt",
+	"b/2.txt",
+	"b/c/3.txt",
+	"ba.txt",
+	"c/4.txt",
+	"d.txt",
+}
+
+// newTestBucket writes the test keys to a bucket in a temporary buckets directory
+func newTestBucket(t *testing.T) {
+	t.Helper()
+
+	bucketsDir := t.TempDir()
+
+	t.Setenv("LOCAL_BUCKETS_DIR", bucketsDir)
+
+	previous := env.LOCAL_BUCKETS_DIR
+	env.LOCAL_BUCKETS_DIR = coreenv.GetEnv("LOCAL_BUCKETS_DIR", bucketsDir)
+
+	t.Cleanup(func() {
+		env.LOCAL_BUCKETS_DIR = previous
+	})
+
+	for _, key := range testBucketKeys {
+		path := blobPath(testBucket, key)
+
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, []byte(key), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type testListPage struct {
+	Files          []string
+	CommonPrefixes []string
+	IsTruncated    bool
+	NextStartAfter string
+}
+
+func toTestListPage(page *listPage) testListPage {
+	files := []string{}
+	for _, file := range page.Files {
+		files = append(files, file.Key)
+	}
+
+	return testListPage{
+		Files:          files,
+		CommonPrefixes: page.CommonPrefixes,
+		IsTruncated:    page.IsTruncated,
+		NextStartAfter: page.NextStartAfter,
+	}
+}
+
+func TestListBucket(t *testing.T) {
+	for i, tt := range []struct {
+		opts     listOptions
+		expected testListPage
+	}{
+		{
+			opts: listOptions{},
+			expected: testListPage{
+				Files:          testBucketKeys,
+				CommonPrefixes: []string{},
+			},
+		},
+		{
+			opts: listOptions{Prefix: "b"},
+			expected: testListPage{
+				Files:          []string{"b/1.txt", "b/2.txt", "b/c/3.txt", "ba.txt"},
+				CommonPrefixes: []string{},
+			},
+		},
+		{
+			opts: listOptions{Prefix: "b/"},
+			expected: testListPage{
+				Files:          []string{"b/1.txt", "b/2.txt", "b/c/3.txt"},
+				CommonPrefixes: []string{},
+			},
+		},
+		{
+			opts: listOptions{Prefix: "b/c/3"},
+			expected: testListPage{
+				Files:          []string{"b/c/3.txt"},
+				CommonPrefixes: []string{},
+			},
+		},
+		{
+			opts: listOptions{Prefix: "missing/"},
+			expected: testListPage{
+				Files:          []string{},
+				CommonPrefixes: []string{},
+			},
+		},
+		{
+			opts: listOptions{Delimiter: "/"},
+			expected: testListPage{
+				Files:          []string{"a.txt", "ba.txt", "d.txt"},
+				CommonPrefixes: []string{"b/", "c/"},
+			},
+		},
+		{
+			opts: listOptions{Prefix: "b", Delimiter: "/"},
+			expected: testListPage{
+				Files:          []string{"ba.txt"},
+				CommonPrefixes: []string{"b/"},
+			},
+		},
+		{
+			opts: listOptions{Prefix: "b/", Delimiter: "/"},
+			expected: testListPage{
+				Files:          []string{"b/1.txt", "b/2.txt"},
+				CommonPrefixes: []string{"b/c/"},
+			},
+		},
+		{
+			opts: listOptions{Delimiter: ".txt"},
+			expected: testListPage{
+				Files:          []string{},
+				CommonPrefixes: []string{"a.txt", "b/1.txt", "b/2.txt", "b/c/3.txt", "ba.txt", "c/4.txt", "d.txt"},
+			},
+		},
+		{
+			opts: listOptions{MaxKeys: 2},
+			expected: testListPage{
+				Files:          []string{"a.txt", "b/1.txt"},
+				CommonPrefixes: []string{},
+				IsTruncated:    true,
+				NextStartAfter: "b/1.txt",
+			},
+		},
+		{
+			opts: listOptions{StartAfter: "b/1.txt", MaxKeys: 2},
+			expected: testListPage{
+				Files:          []string{"b/2.txt", "b/c/3.txt"},
+				CommonPrefixes: []string{},
+				IsTruncated:    true,
+				NextStartAfter: "b/c/3.txt",
+			},
+		},
+		{
+			// the last page is not truncated when it is exactly full
+			opts: listOptions{StartAfter: "c/4.txt", MaxKeys: 1},
+			expected: testListPage{
+				Files:          []string{"d.txt"},
+				CommonPrefixes: []string{},
+			},
+		},
+		{
+			// start after a key that doesn't exist
+			opts: listOptions{StartAfter: "b/10.txt"},
+			expected: testListPage{
+				Files:          []string{"b/2.txt", "b/c/3.txt", "ba.txt", "c/4.txt", "d.txt"},
+				CommonPrefixes: []string{},
+			},
+		},
+		{
+			opts: listOptions{Delimiter: "/", MaxKeys: 2},
+			expected: testListPage{
+				Files:          []string{"a.txt"},
+				CommonPrefixes: []string{"b/"},
+				IsTruncated:    true,
+				NextStartAfter: "b/",
+			},
+		},
+		{
+			// keys in a common prefix listed on a previous page are skipped
+			opts: listOptions{Delimiter: "/", StartAfter: "b/", MaxKeys: 2},
+			expected: testListPage{
+				Files:          []string{"ba.txt"},
+				CommonPrefixes: []string{"c/"},
+				IsTruncated:    true,
+				NextStartAfter: "c/",
+			},
+		},
+		{
+			opts: listOptions{Delimiter: "/", StartAfter: "c/", MaxKeys: 2},
+			expected: testListPage{
+				Files:          []string{"d.txt"},
+				CommonPrefixes: []string{},
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("test listBucket: %d", i), func(t *testing.T) {
+			newTestBucket(t)
+
+			page, err := listBucket(testBucket, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := toTestListPage(page)
+
+			if !cmp.Equal(tt.expected, actual) {
+				t.Error(cmp.Diff(tt.expected, actual))
+			}
+		})
+	}
+}
+
+func TestListBucketPages(t *testing.T) {
+	newTestBucket(t)
+
+	for _, opts := range []listOptions{
+		{},
+		{Prefix: "b"},
+		{Delimiter: "/"},
+		{Prefix: "b/", Delimiter: "/"},
+	} {
+		expected, err := listBucket(testBucket, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for maxKeys := 1; maxKeys <= len(testBucketKeys); maxKeys++ {
+			t.Run(fmt.Sprintf("test listBucket pages: %+v with %d keys per page", opts, maxKeys), func(t *testing.T) {
+				// listing page by page should list the same keys and common prefixes as listing everything at once
+				actual := testListPage{
+					Files:          []string{},
+					CommonPrefixes: []string{},
+				}
+
+				pageOpts := opts
+				pageOpts.MaxKeys = maxKeys
+
+				for {
+					page, err := listBucket(testBucket, pageOpts)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if len(page.Files)+len(page.CommonPrefixes) > maxKeys {
+						t.Fatalf("expected at most %d keys in a page, got %d", maxKeys, len(page.Files)+len(page.CommonPrefixes))
+					}
+
+					pageList := toTestListPage(page)
+					actual.Files = append(actual.Files, pageList.Files...)
+					actual.CommonPrefixes = append(actual.CommonPrefixes, pageList.CommonPrefixes...)
+
+					if !page.IsTruncated {
+						break
+					}
+
+					pageOpts.StartAfter = page.NextStartAfter
+				}
+
+				if !cmp.Equal(toTestListPage(expected), actual) {
+					t.Error(cmp.Diff(toTestListPage(expected), actual))
+				}
+			})
+		}
+	}
+}
+
+func TestBucketExists(t *testing.T) {
+	newTestBucket(t)
+
+	s := &LocalStorageService{}
+	s.SetDeclaredBuckets([]BucketName{"declared-bucket"})
+
+	for i, tt := range []struct {
+		bucket   string
+		expected bool
+	}{
+		{bucket: testBucket, expected: true},
+		// declared buckets exist before anything is written to them
+		{bucket: "declared-bucket", expected: true},
+		{bucket: "missing-bucket", expected: false},
+	} {
+		t.Run(fmt.Sprintf("test bucketExists: %d", i), func(t *testing.T) {
+			actual, err := s.bucketExists(tt.bucket)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual != tt.expected {
+				t.Errorf("expected bucketExists(%q) to be %t", tt.bucket, tt.expected)
+			}
+
+			// checking a bucket shouldn't create it
+			if _, err := os.Stat(filepath.Join(env.LOCAL_BUCKETS_DIR.String(), tt.bucket)); tt.bucket != testBucket && !os.IsNotExist(err) {
+				t.Errorf("expected bucket %q not to be created, got %v", tt.bucket, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"io"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/nitrictech/cli/pkg/cloud/env"
+
+	grpc_errors "github.com/nitrictech/nitric/core/pkg/grpc/errors"
+	storagepb "github.com/nitrictech/nitric/core/pkg/proto/storage/v1"
+)
+
+const uploadsDirName = "uploads"
+
+// sniffLen is the number of bytes used to detect a content type, see http.DetectContentType
+const sniffLen = 512
+
+// headWriter keeps the first bytes written to it, used to detect the content type of a streamed blob
+type headWriter struct {
+	head []byte
+}
+
+func (h *headWriter) Write(p []byte) (int, error) {
+	if remaining := sniffLen - len(h.head); remaining > 0 {
+		h.head = append(h.head, p[:min(remaining, len(p))]...)
+	}
+
+	return len(p), nil
+}
+
+// WriteStream writes a blob from a reader without buffering it in memory. The content type is detected when empty.
+// The blob is written to a temporary file first, so it is only replaced once the whole body has been read.
+func (r *LocalStorageService) WriteStream(ctx context.Context, bucket string, key string, body io.Reader, contentType string) error {
+	newErr := grpc_errors.ErrorsWithScope("DevStorageService.Write")
+
+	err := r.ensureBucketExists(ctx, bucket)
+	if err != nil {
+		return newErr(
+			codes.FailedPrecondition,
+			"failed to get bucket",
+			err,
+		)
+	}
+
+	fileRef := blobPath(bucket, key)
+
+	// Ensure the directory structure exists
+	err = os.MkdirAll(filepath.Dir(fileRef), os.ModePerm)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not create bucket",
+			err,
+		)
+	}
+
+	uploadsDir := filepath.Join(env.LOCAL_STORAGE_DIR.String(), uploadsDirName)
+
+	err = os.MkdirAll(uploadsDir, os.ModePerm)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not create uploads directory",
+			err,
+		)
+	}
+
+	tmpFile, err := os.CreateTemp(uploadsDir, "blob-*")
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not write to file",
+			err,
+		)
+	}
+	// CreateTemp only allows the owner to access the file, blobs are readable like any other file in the project
+	err = tmpFile.Chmod(0o644)
+	if err != nil {
+		tmpFile.Close()
+
+		return newErr(
+			codes.Internal,
+			"could not write to file",
+			err,
+		)
+	}
+
+	// cleans up failed writes, the temporary file no longer exists once it has been renamed
+	defer os.Remove(tmpFile.Name())
+
+	hash := md5.New()
+	head := &headWriter{}
+
+	_, err = io.Copy(io.MultiWriter(tmpFile, hash, head), body)
+
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = r.archiveBlob(bucket, key, false)
+	}
+
+	if err == nil {
+		err = moveFile(tmpFile.Name(), fileRef)
+	}
+
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not write to file",
+			err,
+		)
+	}
+
+	if contentType == "" {
+		contentType = detectContentType(key, head.head)
+	}
+
+	err = r.storeBlobMetadata(bucket, key, contentType, quotedETag(hash.Sum(nil)))
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not store file metadata",
+			err,
+		)
+	}
+
+	go r.triggerBucketNotifications(ctx, bucket, key, storagepb.BlobEventType_Created)
+
+	return nil
+}
+
+// moveFile renames a file, copying it instead when the rename fails, e.g. when the storage and buckets directories are on different filesystems.
+// The source file is left for the caller to remove.
+func moveFile(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dstFile, srcFile)
+
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// OpenBlob opens a blob for streaming reads, returns an error satisfying os.IsNotExist if the blob doesn't exist.
+// The caller must close the returned file.
+func (r *LocalStorageService) OpenBlob(bucket string, key string) (*os.File, *BlobInfo, error) {
+	blobInfo, err := r.GetBlobInfo(bucket, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(blobPath(bucket, key))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, blobInfo, nil
+}
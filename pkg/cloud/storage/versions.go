@@ -0,0 +1,202 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/google/uuid"
+
+	"github.com/nitrictech/cli/pkg/cloud/env"
+)
+
+const (
+	versionsDirName    = "versions"
+	versionsBucketNode = "versions"
+)
+
+// blobVersion is a previous version of a blob, kept when a blob in a versioned bucket is overwritten or deleted
+type blobVersion struct {
+	ID           string `storm:"id"`
+	Key          string `storm:"index"`
+	ContentType  string
+	ETag         string
+	Size         int64
+	LastModified time.Time
+	ArchivedAt   time.Time
+	// The version was kept because the blob was deleted, rather than overwritten
+	Deleted bool
+}
+
+type BlobVersion struct {
+	VersionID    string    `json:"versionId"`
+	Key          string    `json:"key"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	ETag         string    `json:"etag"`
+	ArchivedAt   time.Time `json:"archivedAt"`
+	Deleted      bool      `json:"deleted"`
+}
+
+// VersioningEnabled returns true if previous versions of the blobs in the bucket are kept
+func (r *LocalStorageService) VersioningEnabled(bucket string) bool {
+	return r.localConfig.Buckets[bucket].Versioning
+}
+
+func versionPath(bucket string, versionId string) string {
+	return filepath.Join(env.LOCAL_STORAGE_DIR.String(), versionsDirName, bucket, versionId)
+}
+
+// copyFile copies a file without buffering it in memory, used when the file can't be hard linked
+func copyFile(src string, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dstFile, srcFile)
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// archiveBlob keeps the current version of a blob before it is overwritten or deleted, if the bucket is versioned
+func (r *LocalStorageService) archiveBlob(bucket string, key string, deleted bool) error {
+	if !r.VersioningEnabled(bucket) {
+		return nil
+	}
+
+	blobInfo, err := r.GetBlobInfo(bucket, key)
+	if err != nil {
+		// nothing to keep for new blobs
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	version := &blobVersion{
+		ID:           uuid.New().String(),
+		Key:          key,
+		ContentType:  blobInfo.ContentType,
+		ETag:         blobInfo.ETag,
+		Size:         blobInfo.Size,
+		LastModified: blobInfo.LastModified,
+		ArchivedAt:   time.Now(),
+		Deleted:      deleted,
+	}
+
+	dst := versionPath(bucket, version.ID)
+
+	err = os.MkdirAll(filepath.Dir(dst), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// a hard link avoids copying large files, the blob itself is replaced rather than modified in place
+	err = os.Link(blobPath(bucket, key), dst)
+	if err != nil {
+		err = copyFile(blobPath(bucket, key), dst)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.db.From(bucket, versionsBucketNode).Save(version)
+}
+
+// ListBlobVersions returns the previous versions of a blob, newest first. All versions in the bucket are returned when the key is empty.
+func (r *LocalStorageService) ListBlobVersions(bucket string, key string) ([]*BlobVersion, error) {
+	versions := []*blobVersion{}
+
+	matchers := []q.Matcher{}
+	if key != "" {
+		matchers = append(matchers, q.Eq("Key", key))
+	}
+
+	err := r.db.From(bucket, versionsBucketNode).Select(matchers...).Find(&versions)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].ArchivedAt.After(versions[j].ArchivedAt)
+	})
+
+	blobVersions := make([]*BlobVersion, 0, len(versions))
+
+	for _, version := range versions {
+		blobVersions = append(blobVersions, &BlobVersion{
+			VersionID:    version.ID,
+			Key:          version.Key,
+			ContentType:  version.ContentType,
+			Size:         version.Size,
+			LastModified: version.LastModified,
+			ETag:         version.ETag,
+			ArchivedAt:   version.ArchivedAt,
+			Deleted:      version.Deleted,
+		})
+	}
+
+	return blobVersions, nil
+}
+
+// RestoreBlobVersion makes a previous version the current version of a blob, triggering the same notifications as a write.
+// The version being replaced is kept, so a restore can be undone.
+func (r *LocalStorageService) RestoreBlobVersion(ctx context.Context, bucket string, key string, versionId string) error {
+	version := &blobVersion{}
+
+	err := r.db.From(bucket, versionsBucketNode).One("ID", versionId, version)
+	if err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return fmt.Errorf("version %s of %s not found", versionId, key)
+		}
+
+		return err
+	}
+
+	if version.Key != key {
+		return fmt.Errorf("version %s of %s not found", versionId, key)
+	}
+
+	file, err := os.Open(versionPath(bucket, versionId))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return r.WriteStream(ctx, bucket, key, file, version.ContentType)
+}
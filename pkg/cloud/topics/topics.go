@@ -18,16 +18,28 @@ package topics
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/asaskevich/EventBus"
+	"github.com/asdine/storm"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/nitrictech/cli/pkg/cloud/env"
 	"github.com/nitrictech/cli/pkg/grpcx"
+	"github.com/nitrictech/cli/pkg/project/localconfig"
+	"github.com/nitrictech/cli/pkg/system"
 
 	grpc_errors "github.com/nitrictech/nitric/core/pkg/grpc/errors"
 	"github.com/nitrictech/nitric/core/pkg/logger"
@@ -43,11 +55,20 @@ type (
 type State = map[topicName]map[serviceName]int
 
 type LocalTopicsAndSubscribersService struct {
-	*topics.SubscriberManager
-	subscribers State
+	// serviceSubscribers holds a subscriber manager per service, so each publish can report the outcome for every subscribing service
+	serviceSubscribers map[serviceName]*topics.SubscriberManager
+	subscribers        State
 
 	subscribersLock sync.RWMutex
 
+	// db persists pending delayed events, so they survive restarts
+	db *storm.DB
+	// delayedTimers holds the timer of each pending delayed event, keyed by event id
+	delayedTimers map[string]*time.Timer
+	delayedLock   sync.Mutex
+
+	config map[topicName]localconfig.LocalTopicConfiguration
+
 	bus EventBus.Bus
 }
 
@@ -55,17 +76,150 @@ type ActionState struct {
 	TopicName string
 	Payload   string
 	Success   bool
+	// Delay is the number of seconds the event was delayed before delivery, for retries this is the backoff since the last attempt
+	Delay int
+	// Attempt is the number of this delivery attempt, starting at 1
+	Attempt int
+	// Error is the error that caused the delivery to fail, if any
+	Error string
+	// DeadLettered is true if the delivery failed and the event exhausted its attempts
+	DeadLettered bool
+	// Subscribers is the outcome of the delivery for each subscribing service
+	Subscribers []SubscriberResult
+}
+
+// SubscriberResult - the outcome of delivering an event to a subscribing service
+type SubscriberResult struct {
+	ServiceName string
+	Delivered   bool
+	// Error is the error that prevented delivery to the service, if any
+	Error    string
+	Duration time.Duration
+}
+
+// DelayedEvent - an event published with a delay, which is pending delivery
+type DelayedEvent struct {
+	Id        string `storm:"id"`
+	TopicName string `storm:"index"`
+	// Payload is the JSON payload of the event
+	Payload string
+	// PublishedAt is when the event was published, or for retries when the retry was scheduled
+	PublishedAt time.Time
+	DeliverAt   time.Time
+	// WaitingForSubscribers is true once the event is due, but there are no subscribers to deliver it to
+	WaitingForSubscribers bool
+	// Attempts is the number of failed delivery attempts made before the event was delayed for retry
+	Attempts int
+	// Services are the services the event is delivered to, every subscribing service when empty.
+	// Retries are only delivered to the services that failed to handle the event.
+	Services []string
+	// Request is the protobuf encoded topicspb.TopicPublishRequest
+	Request []byte
+}
+
+// DelayedEventInfo - a summary of an event pending delayed delivery
+type DelayedEventInfo struct {
+	Id                    string    `json:"id"`
+	TopicName             string    `json:"topicName"`
+	Payload               string    `json:"payload"`
+	PublishedAt           time.Time `json:"publishedAt"`
+	DeliverAt             time.Time `json:"deliverAt"`
+	WaitingForSubscribers bool      `json:"waitingForSubscribers"`
+	Attempts              int       `json:"attempts"`
+	// Retry is true if the event is delayed for retry after a failed delivery, rather than published with a delay
+	Retry    bool     `json:"retry"`
+	Services []string `json:"services,omitempty"`
+}
+
+func (e *DelayedEvent) info() DelayedEventInfo {
+	return DelayedEventInfo{
+		Id:                    e.Id,
+		TopicName:             e.TopicName,
+		Payload:               e.Payload,
+		PublishedAt:           e.PublishedAt,
+		DeliverAt:             e.DeliverAt,
+		WaitingForSubscribers: e.WaitingForSubscribers,
+		Attempts:              e.Attempts,
+		Retry:                 e.Attempts > 0,
+		Services:              e.Services,
+	}
+}
+
+func (e *DelayedEvent) publishRequest() (*topicspb.TopicPublishRequest, error) {
+	req := &topicspb.TopicPublishRequest{}
+
+	err := proto.Unmarshal(e.Request, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// DeadLetterEvent - an event that exhausted its delivery attempts
+type DeadLetterEvent struct {
+	Id        string `storm:"id"`
+	TopicName string `storm:"index"`
+	// Payload is the JSON payload of the event
+	Payload  string
+	Attempts int
+	FailedAt time.Time
+	// Error is the error returned by the last delivery attempt, if any
+	Error string
+	// Services are the services that failed to handle the event, redeliveries are only delivered to them
+	Services []string
+	// Request is the protobuf encoded topicspb.TopicPublishRequest
+	Request []byte
+}
+
+// DeadLetterEventInfo - a summary of an event in the dead-letter store
+type DeadLetterEventInfo struct {
+	Id        string    `json:"id"`
+	TopicName string    `json:"topicName"`
+	Payload   string    `json:"payload"`
+	Attempts  int       `json:"attempts"`
+	FailedAt  time.Time `json:"failedAt"`
+	Error     string    `json:"error,omitempty"`
+	Services  []string  `json:"services,omitempty"`
+}
+
+func (e *DeadLetterEvent) info() DeadLetterEventInfo {
+	return DeadLetterEventInfo{
+		Id:        e.Id,
+		TopicName: e.TopicName,
+		Payload:   e.Payload,
+		Attempts:  e.Attempts,
+		FailedAt:  e.FailedAt,
+		Error:     e.Error,
+		Services:  e.Services,
+	}
 }
 
+const topicsDbName = "topics.db"
+
+// waitingForSubscribersInterval is how often a due delayed event retries delivery while its topic has no subscribers
+var waitingForSubscribersInterval = 1 * time.Second
+
 var (
-	_ topicspb.TopicsServer     = (*LocalTopicsAndSubscribersService)(nil)
-	_ topicspb.SubscriberServer = (*LocalTopicsAndSubscribersService)(nil)
+	defaultInitialBackoff    = 1 * time.Second
+	defaultMaxBackoff        = 60 * time.Second
+	defaultBackoffMultiplier = 2.0
+)
+
+var (
+	_ topicspb.TopicsServer             = (*LocalTopicsAndSubscribersService)(nil)
+	_ topicspb.SubscriberServer         = (*LocalTopicsAndSubscribersService)(nil)
+	_ topics.SubscriptionRequestHandler = (*LocalTopicsAndSubscribersService)(nil)
 )
 
 const localTopicsTopic = "local_topics"
 
 const localTopicsDeliveryTopic = "local_topics_delivery"
 
+const localTopicsDelayedTopic = "local_topics_delayed"
+
+const localTopicsDeadLetterTopic = "local_topics_dead_letter"
+
 func (s *LocalTopicsAndSubscribersService) publishState() {
 	s.bus.Publish(localTopicsTopic, maps.Clone(s.subscribers))
 }
@@ -84,6 +238,38 @@ func (s *LocalTopicsAndSubscribersService) SubscribeToAction(subscription func(A
 	_ = s.bus.Subscribe(localTopicsDeliveryTopic, subscription)
 }
 
+func (s *LocalTopicsAndSubscribersService) publishDelayedEvents() {
+	delayedEvents, err := s.GetDelayedEvents()
+	if err != nil {
+		logger.Errorf("error reading delayed events: %s", err.Error())
+		return
+	}
+
+	s.bus.Publish(localTopicsDelayedTopic, delayedEvents)
+}
+
+// SubscribeToDelayedEvents subscribes to changes in the events pending delayed delivery
+func (s *LocalTopicsAndSubscribersService) SubscribeToDelayedEvents(subscription func([]DelayedEventInfo)) {
+	// ignore the error, it's only returned if the fn param isn't a function
+	_ = s.bus.Subscribe(localTopicsDelayedTopic, subscription)
+}
+
+func (s *LocalTopicsAndSubscribersService) publishDeadLetterEvents() {
+	deadLetterEvents, err := s.GetDeadLetterEvents()
+	if err != nil {
+		logger.Errorf("error reading dead-letter events: %s", err.Error())
+		return
+	}
+
+	s.bus.Publish(localTopicsDeadLetterTopic, deadLetterEvents)
+}
+
+// SubscribeToDeadLetterEvents subscribes to changes in the events held in the dead-letter store
+func (s *LocalTopicsAndSubscribersService) SubscribeToDeadLetterEvents(subscription func([]DeadLetterEventInfo)) {
+	// ignore the error, it's only returned if the fn param isn't a function
+	_ = s.bus.Subscribe(localTopicsDeadLetterTopic, subscription)
+}
+
 func (s *LocalTopicsAndSubscribersService) GetSubscribers() map[string]map[string]int {
 	s.subscribersLock.RLock()
 	defer s.subscribersLock.RUnlock()
@@ -158,22 +344,214 @@ func (s *LocalTopicsAndSubscribersService) Subscribe(stream topicspb.Subscriber_
 	s.registerSubscriber(serviceName, firstRequest.GetRegistrationRequest())
 	defer s.unregisterSubscriber(serviceName, firstRequest.GetRegistrationRequest())
 
-	return s.SubscriberManager.Subscribe(peekableStream)
+	return s.subscriberManager(serviceName).Subscribe(peekableStream)
+}
+
+// subscriberManager returns the subscriber manager of a service, creating it if needed
+func (s *LocalTopicsAndSubscribersService) subscriberManager(serviceName string) *topics.SubscriberManager {
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+
+	manager, ok := s.serviceSubscribers[serviceName]
+	if !ok {
+		manager = topics.New()
+		s.serviceSubscribers[serviceName] = manager
+	}
+
+	return manager
+}
+
+func (s *LocalTopicsAndSubscribersService) WorkerCount() int {
+	s.subscribersLock.RLock()
+	defer s.subscribersLock.RUnlock()
+
+	workerCount := 0
+
+	for _, manager := range s.serviceSubscribers {
+		workerCount += manager.WorkerCount()
+	}
+
+	return workerCount
 }
 
-func (s *LocalTopicsAndSubscribersService) deliverEvent(ctx context.Context, req *topicspb.TopicPublishRequest) error {
-	msg := &topicspb.ServerMessage{
-		Content: &topicspb.ServerMessage_MessageRequest{
-			MessageRequest: &topicspb.MessageRequest{
-				TopicName: req.TopicName,
-				Message:   req.Message,
+// HandleRequest delivers a message to every service subscribed to its topic
+func (s *LocalTopicsAndSubscribersService) HandleRequest(request *topicspb.ServerMessage) (*topicspb.ClientMessage, error) {
+	if request.GetMessageRequest() == nil {
+		return nil, fmt.Errorf("invalid request, expected message request")
+	}
+
+	success, _, err := s.fanOut(request.GetMessageRequest(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &topicspb.ClientMessage{
+		Id: request.Id,
+		Content: &topicspb.ClientMessage_MessageResponse{
+			MessageResponse: &topicspb.MessageResponse{
+				Success: success,
 			},
 		},
+	}, nil
+}
+
+// fanOut delivers a message to the services subscribed to its topic in parallel, returning true if every service handled it successfully.
+// The message is delivered to every subscribing service when services is empty.
+func (s *LocalTopicsAndSubscribersService) fanOut(messageRequest *topicspb.MessageRequest, services []string) (bool, []SubscriberResult, error) {
+	s.subscribersLock.RLock()
+	managers := maps.Clone(s.serviceSubscribers)
+	s.subscribersLock.RUnlock()
+
+	if len(services) > 0 {
+		maps.DeleteFunc(managers, func(serviceName string, _ *topics.SubscriberManager) bool {
+			return !slices.Contains(services, serviceName)
+		})
 	}
 
-	resp, err := s.SubscriberManager.HandleRequest(msg)
-	if err != nil {
-		return err
+	resultsLock := sync.Mutex{}
+	results := []SubscriberResult{}
+	errs := []error{}
+	wg := sync.WaitGroup{}
+
+	for serviceName, manager := range managers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+
+			resp, err := manager.HandleRequest(&topicspb.ServerMessage{
+				Content: &topicspb.ServerMessage_MessageRequest{
+					MessageRequest: messageRequest,
+				},
+			})
+			if isNoWorkersError(err) {
+				// the service doesn't subscribe to this topic
+				return
+			}
+
+			result := SubscriberResult{
+				ServiceName: serviceName,
+				Delivered:   err == nil && resp.GetMessageResponse().GetSuccess(),
+				Duration:    time.Since(start),
+			}
+
+			if err != nil {
+				result.Error = err.Error()
+			} else if !result.Delivered {
+				result.Error = "subscriber returned an unsuccessful response"
+			}
+
+			resultsLock.Lock()
+			defer resultsLock.Unlock()
+
+			results = append(results, result)
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", serviceName, err))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(results) == 0 {
+		return false, nil, fmt.Errorf("no workers registered for topic subscription: %s", messageRequest.GetTopicName())
+	}
+
+	slices.SortFunc(results, func(a, b SubscriberResult) int {
+		return strings.Compare(a.ServiceName, b.ServiceName)
+	})
+
+	success := true
+
+	for _, result := range results {
+		if !result.Delivered {
+			success = false
+
+			system.Logf("delivery of event for topic '%s' to service '%s' failed: %s", messageRequest.GetTopicName(), result.ServiceName, result.Error)
+		}
+	}
+
+	return success, results, errors.Join(errs...)
+}
+
+// deliverEvent sends an event to the subscribers of its topic, or only the given services, returning true if every subscriber handled it successfully
+func (s *LocalTopicsAndSubscribersService) deliverEvent(req *topicspb.TopicPublishRequest, services []string) (bool, []SubscriberResult, error) {
+	return s.fanOut(&topicspb.MessageRequest{
+		TopicName: req.TopicName,
+		Message:   req.Message,
+	}, services)
+}
+
+// failedServices returns the names of the services that failed to handle a delivery
+func failedServices(results []SubscriberResult) []string {
+	services := []string{}
+
+	for _, result := range results {
+		if !result.Delivered {
+			services = append(services, result.ServiceName)
+		}
+	}
+
+	return services
+}
+
+// retryBackoff returns how long to wait before retrying an event after the given number of failed attempts
+func retryBackoff(config localconfig.LocalTopicConfiguration, attempts int) time.Duration {
+	initialBackoff := defaultInitialBackoff
+	if config.InitialBackoff > 0 {
+		initialBackoff = time.Duration(config.InitialBackoff) * time.Second
+	}
+
+	maxBackoff := defaultMaxBackoff
+	if config.MaxBackoff > 0 {
+		maxBackoff = time.Duration(config.MaxBackoff) * time.Second
+	}
+
+	multiplier := defaultBackoffMultiplier
+	if config.BackoffMultiplier > 0 {
+		multiplier = config.BackoffMultiplier
+	}
+
+	backoff := float64(initialBackoff) * math.Pow(multiplier, float64(attempts-1))
+	if backoff > float64(maxBackoff) {
+		return maxBackoff
+	}
+
+	return time.Duration(backoff)
+}
+
+// handleFailedDelivery retries a failed delivery according to the retry policy of its topic, moving the event to the dead-letter store once its attempts are exhausted.
+// Only the services that failed are retried, so services that handled the event don't receive it again.
+// Returns true if the event was dead-lettered. Topics without a retry policy return the delivery error unchanged.
+func (s *LocalTopicsAndSubscribersService) handleFailedDelivery(req *topicspb.TopicPublishRequest, attempt int, results []SubscriberResult, deliveryErr error) (bool, error) {
+	config, ok := s.config[req.TopicName]
+	if !ok || config.MaxAttempts < 1 {
+		return false, deliveryErr
+	}
+
+	if attempt < config.MaxAttempts {
+		backoff := retryBackoff(config, attempt)
+
+		system.Logf("delivery attempt %d of %d for topic '%s' failed, retrying in %s", attempt, config.MaxAttempts, req.TopicName, backoff)
+
+		return false, s.storeDelayedEvent(req, backoff, attempt, failedServices(results))
+	}
+
+	system.Logf("event for topic '%s' failed after %d attempts, moved to the dead-letter store", req.TopicName, attempt)
+
+	return true, s.storeDeadLetterEvent(req, attempt, failedServices(results), deliveryErr)
+}
+
+// attemptDelivery delivers an event, recording the outcome of the attempt and handling failures according to the retry policy of its topic.
+// attempt is the number of this delivery attempt, starting at 1, and delay is how long the event waited before this attempt.
+// The event is delivered to every subscribing service when services is empty.
+func (s *LocalTopicsAndSubscribersService) attemptDelivery(req *topicspb.TopicPublishRequest, attempt int, delay time.Duration, services []string) error {
+	success, results, deliveryErr := s.deliverEvent(req, services)
+	if isNoWorkersError(deliveryErr) {
+		return deliveryErr
 	}
 
 	json, err := req.Message.GetStructPayload().MarshalJSON()
@@ -181,27 +559,400 @@ func (s *LocalTopicsAndSubscribersService) deliverEvent(ctx context.Context, req
 		return err
 	}
 
-	s.publishAction(ActionState{
-		TopicName: req.TopicName,
-		Success:   resp.GetMessageResponse().GetSuccess(),
-		Payload:   string(json),
-	})
+	action := ActionState{
+		TopicName:   req.TopicName,
+		Success:     success,
+		Payload:     string(json),
+		Delay:       int(delay.Seconds()),
+		Attempt:     attempt,
+		Subscribers: results,
+	}
+
+	if deliveryErr != nil {
+		action.Error = deliveryErr.Error()
+	}
+
+	if !success {
+		action.DeadLettered, err = s.handleFailedDelivery(req, attempt, results, deliveryErr)
+	}
+
+	s.publishAction(action)
 
 	return err
 }
 
+func isNoWorkersError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "no workers registered")
+}
+
 // "no workers registered" is not an error when it occurs locally, so we suppress it
 func warnIfNoWorkersError(err error, topic string) error {
-	if err == nil {
+	if isNoWorkersError(err) {
+		logger.Warnf("topic '%s' has no subscribers", topic)
+		return nil
+	}
+
+	return err
+}
+
+// GetDelayedEvents returns all events pending delayed delivery, in the order they're due
+func (s *LocalTopicsAndSubscribersService) GetDelayedEvents() ([]DelayedEventInfo, error) {
+	storedEvents := []DelayedEvent{}
+
+	err := s.db.All(&storedEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	delayedEvents := make([]DelayedEventInfo, 0, len(storedEvents))
+
+	for _, storedEvent := range storedEvents {
+		delayedEvents = append(delayedEvents, storedEvent.info())
+	}
+
+	slices.SortFunc(delayedEvents, func(a, b DelayedEventInfo) int {
+		return a.DeliverAt.Compare(b.DeliverAt)
+	})
+
+	return delayedEvents, nil
+}
+
+// scheduleDelayedEvent starts the timer that delivers a delayed event once it's due
+func (s *LocalTopicsAndSubscribersService) scheduleDelayedEvent(id string, after time.Duration) {
+	s.delayedLock.Lock()
+	defer s.delayedLock.Unlock()
+
+	s.delayedTimers[id] = time.AfterFunc(after, func() {
+		err := s.deliverDelayedEvent(id)
+		if err != nil {
+			logger.Errorf("could not publish event: %s", err.Error())
+		}
+	})
+}
+
+// claimDelayedEvent stops the timer of a pending delayed event and returns it, ensuring it is only delivered or cancelled once
+func (s *LocalTopicsAndSubscribersService) claimDelayedEvent(id string) (*DelayedEvent, error) {
+	s.delayedLock.Lock()
+	defer s.delayedLock.Unlock()
+
+	timer, ok := s.delayedTimers[id]
+	if !ok {
+		return nil, fmt.Errorf("delayed event %s not found", id)
+	}
+
+	timer.Stop()
+	delete(s.delayedTimers, id)
+
+	delayedEvent := &DelayedEvent{}
+
+	err := s.db.One("Id", id, delayedEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	return delayedEvent, nil
+}
+
+// storeDelayedEvent persists a delayed event and schedules its delivery after delay, attempts is the number of delivery attempts already made.
+// The event is delivered to every subscribing service when services is empty.
+func (s *LocalTopicsAndSubscribersService) storeDelayedEvent(req *topicspb.TopicPublishRequest, delay time.Duration, attempts int, services []string) error {
+	reqBytes, err := proto.Marshal(req)
+	if err != nil {
 		return err
 	}
 
-	if strings.HasPrefix(err.Error(), "no workers registered") {
-		logger.Warnf("topic '%s' has no subscribers", topic)
+	json, err := req.Message.GetStructPayload().MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	delayedEvent := &DelayedEvent{
+		Id:          uuid.New().String(),
+		TopicName:   req.TopicName,
+		Payload:     string(json),
+		PublishedAt: now,
+		DeliverAt:   now.Add(delay),
+		Attempts:    attempts,
+		Services:    services,
+		Request:     reqBytes,
+	}
+
+	err = s.db.Save(delayedEvent)
+	if err != nil {
+		return err
+	}
+
+	s.scheduleDelayedEvent(delayedEvent.Id, delay)
+
+	s.publishDelayedEvents()
+
+	return nil
+}
+
+// waitForSubscribers keeps a due delayed event whose topic has no subscribers, retrying its delivery until a subscriber is available
+func (s *LocalTopicsAndSubscribersService) waitForSubscribers(delayedEvent *DelayedEvent) error {
+	if !delayedEvent.WaitingForSubscribers {
+		system.Logf("delayed event %s for topic '%s' is waiting for subscribers", delayedEvent.Id, delayedEvent.TopicName)
+
+		delayedEvent.WaitingForSubscribers = true
+
+		err := s.db.Save(delayedEvent)
+		if err != nil {
+			return err
+		}
+
+		s.publishDelayedEvents()
+	}
+
+	s.scheduleDelayedEvent(delayedEvent.Id, waitingForSubscribersInterval)
+
+	return nil
+}
+
+// deliverDelayedEvent delivers a pending delayed event to its subscribers.
+// If the topic has no subscribers, the event waits until a subscriber is available, so events restored from a previous run aren't lost while services start.
+func (s *LocalTopicsAndSubscribersService) deliverDelayedEvent(id string) error {
+	delayedEvent, err := s.claimDelayedEvent(id)
+	if err != nil {
+		return err
+	}
+
+	req, err := delayedEvent.publishRequest()
+	if err != nil {
+		return err
+	}
+
+	err = s.attemptDelivery(req, delayedEvent.Attempts+1, time.Since(delayedEvent.PublishedAt), delayedEvent.Services)
+	if isNoWorkersError(err) {
+		return s.waitForSubscribers(delayedEvent)
+	}
+
+	deleteErr := s.db.DeleteStruct(delayedEvent)
+
+	s.publishDelayedEvents()
+
+	return errors.Join(err, deleteErr)
+}
+
+// ReleaseDelayedEvent delivers a pending delayed event immediately.
+// If the topic has no subscribers, the event waits for a subscriber the same as a delayed event that is due.
+func (s *LocalTopicsAndSubscribersService) ReleaseDelayedEvent(id string) error {
+	newErr := grpc_errors.ErrorsWithScope("WorkerPoolEventService.ReleaseDelayedEvent")
+
+	delayedEvent, err := s.claimDelayedEvent(id)
+	if err != nil {
+		return newErr(
+			codes.NotFound,
+			"could not release delayed event",
+			err,
+		)
+	}
+
+	req, err := delayedEvent.publishRequest()
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not read delayed event",
+			err,
+		)
+	}
+
+	err = s.attemptDelivery(req, delayedEvent.Attempts+1, time.Since(delayedEvent.PublishedAt), delayedEvent.Services)
+	if isNoWorkersError(err) {
+		// keep the event until its topic has subscribers, rather than dropping it
+		logger.Warnf("topic '%s' has no subscribers", req.TopicName)
+
+		err = s.waitForSubscribers(delayedEvent)
+		if err != nil {
+			return newErr(
+				codes.Internal,
+				"could not reschedule delayed event",
+				err,
+			)
+		}
+
 		return nil
 	}
 
-	return err
+	deleteErr := s.db.DeleteStruct(delayedEvent)
+
+	s.publishDelayedEvents()
+
+	if err = errors.Join(err, deleteErr); err != nil {
+		return newErr(
+			codes.Internal,
+			"could not publish event",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// CancelDelayedEvent removes a pending delayed event without delivering it
+func (s *LocalTopicsAndSubscribersService) CancelDelayedEvent(id string) error {
+	newErr := grpc_errors.ErrorsWithScope("WorkerPoolEventService.CancelDelayedEvent")
+
+	delayedEvent, err := s.claimDelayedEvent(id)
+	if err != nil {
+		return newErr(
+			codes.NotFound,
+			"could not cancel delayed event",
+			err,
+		)
+	}
+
+	err = s.db.DeleteStruct(delayedEvent)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not remove delayed event",
+			err,
+		)
+	}
+
+	s.publishDelayedEvents()
+
+	return nil
+}
+
+// GetDeadLetterEvents returns all events in the dead-letter store, in the order they failed
+func (s *LocalTopicsAndSubscribersService) GetDeadLetterEvents() ([]DeadLetterEventInfo, error) {
+	storedEvents := []DeadLetterEvent{}
+
+	err := s.db.All(&storedEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetterEvents := make([]DeadLetterEventInfo, 0, len(storedEvents))
+
+	for _, storedEvent := range storedEvents {
+		deadLetterEvents = append(deadLetterEvents, storedEvent.info())
+	}
+
+	slices.SortFunc(deadLetterEvents, func(a, b DeadLetterEventInfo) int {
+		return a.FailedAt.Compare(b.FailedAt)
+	})
+
+	return deadLetterEvents, nil
+}
+
+// storeDeadLetterEvent persists an event that exhausted its delivery attempts
+func (s *LocalTopicsAndSubscribersService) storeDeadLetterEvent(req *topicspb.TopicPublishRequest, attempts int, services []string, deliveryErr error) error {
+	reqBytes, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	json, err := req.Message.GetStructPayload().MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	deadLetterEvent := &DeadLetterEvent{
+		Id:        uuid.New().String(),
+		TopicName: req.TopicName,
+		Payload:   string(json),
+		Attempts:  attempts,
+		FailedAt:  time.Now(),
+		Services:  services,
+		Request:   reqBytes,
+	}
+
+	if deliveryErr != nil {
+		deadLetterEvent.Error = deliveryErr.Error()
+	}
+
+	err = s.db.Save(deadLetterEvent)
+	if err != nil {
+		return err
+	}
+
+	s.publishDeadLetterEvents()
+
+	return nil
+}
+
+// RedeliverDeadLetterEvent removes an event from the dead-letter store and delivers it again to the services that failed to handle it, with a fresh set of delivery attempts
+func (s *LocalTopicsAndSubscribersService) RedeliverDeadLetterEvent(id string) error {
+	newErr := grpc_errors.ErrorsWithScope("WorkerPoolEventService.RedeliverDeadLetterEvent")
+
+	deadLetterEvent := &DeadLetterEvent{}
+
+	err := s.db.One("Id", id, deadLetterEvent)
+	if err != nil {
+		return newErr(
+			codes.NotFound,
+			fmt.Sprintf("dead-letter event %s not found", id),
+			err,
+		)
+	}
+
+	req := &topicspb.TopicPublishRequest{}
+
+	err = proto.Unmarshal(deadLetterEvent.Request, req)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not read dead-letter event",
+			err,
+		)
+	}
+
+	err = s.db.DeleteStruct(deadLetterEvent)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not remove dead-letter event",
+			err,
+		)
+	}
+
+	s.publishDeadLetterEvents()
+
+	// deliver in the background, the same as a delayed event that is due
+	err = s.storeDelayedEvent(req, 0, 0, deadLetterEvent.Services)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not redeliver dead-letter event",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// DeleteDeadLetterEvent removes an event from the dead-letter store without delivering it
+func (s *LocalTopicsAndSubscribersService) DeleteDeadLetterEvent(id string) error {
+	newErr := grpc_errors.ErrorsWithScope("WorkerPoolEventService.DeleteDeadLetterEvent")
+
+	deadLetterEvent := &DeadLetterEvent{}
+
+	err := s.db.One("Id", id, deadLetterEvent)
+	if err != nil {
+		return newErr(
+			codes.NotFound,
+			fmt.Sprintf("dead-letter event %s not found", id),
+			err,
+		)
+	}
+
+	err = s.db.DeleteStruct(deadLetterEvent)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"could not remove dead-letter event",
+			err,
+		)
+	}
+
+	s.publishDeadLetterEvents()
+
+	return nil
 }
 
 // Publish a message to a given topic
@@ -209,21 +960,16 @@ func (s *LocalTopicsAndSubscribersService) Publish(ctx context.Context, req *top
 	newErr := grpc_errors.ErrorsWithScope("WorkerPoolEventService.Publish")
 
 	if req.Delay != nil {
-		// TODO: Implement a signal from the front end that allows for the early release of delayed events (by their ID)
-		// TODO: We want the event to appear straight away in the history table (maybe as a new event type that counts down)
-		go func(evt *topicspb.TopicPublishRequest) {
-			// Wait to deliver the events
-			time.Sleep(req.Delay.AsDuration())
-
-			err := s.deliverEvent(ctx, evt)
-
-			err = warnIfNoWorkersError(err, evt.TopicName)
-			if err != nil {
-				logger.Errorf("could not publish event: %s", err.Error())
-			}
-		}(req)
+		err := s.storeDelayedEvent(req, req.Delay.AsDuration(), 0, nil)
+		if err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"could not store delayed event",
+				err,
+			)
+		}
 	} else {
-		err := s.deliverEvent(ctx, req)
+		err := s.attemptDelivery(req, 1, 0, nil)
 
 		err = warnIfNoWorkersError(err, req.TopicName)
 		if err != nil {
@@ -238,12 +984,68 @@ func (s *LocalTopicsAndSubscribersService) Publish(ctx context.Context, req *top
 	return &topicspb.TopicPublishResponse{}, nil
 }
 
+// Close stops all pending delayed event timers and closes the delayed event store
+func (s *LocalTopicsAndSubscribersService) Close() error {
+	s.delayedLock.Lock()
+	defer s.delayedLock.Unlock()
+
+	for id, timer := range s.delayedTimers {
+		timer.Stop()
+		delete(s.delayedTimers, id)
+	}
+
+	return s.db.Close()
+}
+
+type NewLocalTopicsServiceOpts struct {
+	LocalConfig localconfig.LocalConfiguration
+}
+
 // Create new Dev EventService
-func NewLocalTopicsService() (*LocalTopicsAndSubscribersService, error) {
-	return &LocalTopicsAndSubscribersService{
-		SubscriberManager: topics.New(),
-		subscribersLock:   sync.RWMutex{},
-		subscribers:       make(map[string]map[string]int),
-		bus:               EventBus.New(),
-	}, nil
+func NewLocalTopicsService(opts NewLocalTopicsServiceOpts) (*LocalTopicsAndSubscribersService, error) {
+	topicsDir := env.LOCAL_TOPICS_DIR.String()
+
+	// Check whether file exists
+	_, err := os.Stat(topicsDir)
+	if os.IsNotExist(err) {
+		// Make directory if not present
+		err := os.MkdirAll(topicsDir, 0o777)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options := storm.BoltOptions(0o600, &bbolt.Options{Timeout: 1 * time.Second})
+
+	db, err := storm.Open(filepath.Join(topicsDir, topicsDbName), options)
+	if err != nil {
+		return nil, err
+	}
+
+	config := opts.LocalConfig.Topics
+	if config == nil {
+		config = map[topicName]localconfig.LocalTopicConfiguration{}
+	}
+
+	topicsService := &LocalTopicsAndSubscribersService{
+		serviceSubscribers: map[serviceName]*topics.SubscriberManager{},
+		subscribersLock:    sync.RWMutex{},
+		subscribers:        make(map[string]map[string]int),
+		db:                 db,
+		delayedTimers:      map[string]*time.Timer{},
+		config:             config,
+		bus:                EventBus.New(),
+	}
+
+	// resume the delayed events pending from a previous run, overdue events are delivered straight away
+	delayedEvents, err := topicsService.GetDelayedEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delayedEvent := range delayedEvents {
+		topicsService.scheduleDelayedEvent(delayedEvent.Id, time.Until(delayedEvent.DeliverAt))
+	}
+
+	return topicsService, nil
 }
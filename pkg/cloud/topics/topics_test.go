@@ -0,0 +1,380 @@
+// Copyright Nitric Pty Ltd.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nitrictech/cli/pkg/cloud/env"
+	"github.com/nitrictech/cli/pkg/grpcx"
+	"github.com/nitrictech/cli/pkg/project/localconfig"
+	coreenv "github.com/nitrictech/nitric/core/pkg/env"
+	topicspb "github.com/nitrictech/nitric/core/pkg/proto/topics/v1"
+)
+
+// newTestTopicsService opens the topics service with its delayed event store in a temporary directory, closing it when the test ends
+func newTestTopicsService(t *testing.T, localConfig localconfig.LocalConfiguration) *LocalTopicsAndSubscribersService {
+	t.Helper()
+
+	topicsDir := t.TempDir()
+
+	t.Setenv("LOCAL_TOPICS_DIR", topicsDir)
+
+	previous := env.LOCAL_TOPICS_DIR
+	env.LOCAL_TOPICS_DIR = coreenv.GetEnv("LOCAL_TOPICS_DIR", topicsDir)
+
+	t.Cleanup(func() {
+		env.LOCAL_TOPICS_DIR = previous
+	})
+
+	s, err := NewLocalTopicsService(NewLocalTopicsServiceOpts{LocalConfig: localConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	return s
+}
+
+func testPublishRequest(t *testing.T, topicName string) *topicspb.TopicPublishRequest {
+	t.Helper()
+
+	payload, err := structpb.NewStruct(map[string]interface{}{"test": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &topicspb.TopicPublishRequest{
+		TopicName: topicName,
+		Message: &topicspb.TopicMessage{
+			Content: &topicspb.TopicMessage_StructPayload{StructPayload: payload},
+		},
+	}
+}
+
+// testSubscriberStream is the subscriber stream of a service, which handles each event with the result of success
+type testSubscriberStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	topicName string
+	success   bool
+
+	// started is closed once the subscriber is ready to receive events
+	started   chan struct{}
+	responses chan *topicspb.ClientMessage
+
+	lock       sync.Mutex
+	registered bool
+	received   int
+}
+
+func newTestSubscriberStream(serviceName string, topicName string, success bool) *testSubscriberStream {
+	return &testSubscriberStream{
+		ctx:       metadata.NewIncomingContext(context.Background(), metadata.Pairs(grpcx.ServiceNameKey, serviceName)),
+		topicName: topicName,
+		success:   success,
+		started:   make(chan struct{}),
+		responses: make(chan *topicspb.ClientMessage, 10),
+	}
+}
+
+func (s *testSubscriberStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testSubscriberStream) Recv() (*topicspb.ClientMessage, error) {
+	s.lock.Lock()
+
+	if !s.registered {
+		s.registered = true
+		s.lock.Unlock()
+
+		return &topicspb.ClientMessage{
+			Id: "registration",
+			Content: &topicspb.ClientMessage_RegistrationRequest{
+				RegistrationRequest: &topicspb.RegistrationRequest{TopicName: s.topicName},
+			},
+		}, nil
+	}
+
+	select {
+	case <-s.started:
+	default:
+		close(s.started)
+	}
+
+	s.lock.Unlock()
+
+	resp, ok := <-s.responses
+	if !ok {
+		return nil, io.EOF
+	}
+
+	return resp, nil
+}
+
+func (s *testSubscriberStream) Send(msg *topicspb.ServerMessage) error {
+	if msg.GetMessageRequest() == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	s.received++
+	s.lock.Unlock()
+
+	s.responses <- &topicspb.ClientMessage{
+		Id: msg.Id,
+		Content: &topicspb.ClientMessage_MessageResponse{
+			MessageResponse: &topicspb.MessageResponse{Success: s.success},
+		},
+	}
+
+	return nil
+}
+
+// Received returns the number of events delivered to the subscriber
+func (s *testSubscriberStream) Received() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.received
+}
+
+// subscribe connects a subscriber stream to the topics service, disconnecting it when the test ends
+func subscribe(t *testing.T, s *LocalTopicsAndSubscribersService, stream *testSubscriberStream) {
+	t.Helper()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_ = s.Subscribe(stream)
+	}()
+
+	t.Cleanup(func() {
+		close(stream.responses)
+		<-done
+	})
+
+	select {
+	case <-stream.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the subscriber to start")
+	}
+}
+
+func TestReleaseDelayedEventWithoutSubscribers(t *testing.T) {
+	s := newTestTopicsService(t, localconfig.LocalConfiguration{})
+
+	req := testPublishRequest(t, "orders")
+	req.Delay = durationpb.New(time.Hour)
+
+	_, err := s.Publish(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delayedEvents, err := s.GetDelayedEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(delayedEvents) != 1 {
+		t.Fatalf("expected 1 delayed event, got %d", len(delayedEvents))
+	}
+
+	err = s.ReleaseDelayedEvent(delayedEvents[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the released event should wait for subscribers instead of being dropped
+	delayedEvents, err = s.GetDelayedEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(delayedEvents) != 1 || !delayedEvents[0].WaitingForSubscribers {
+		t.Fatalf("expected the released event to be waiting for subscribers, got %+v", delayedEvents)
+	}
+
+	// and can still be cancelled
+	err = s.CancelDelayedEvent(delayedEvents[0].Id)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for i, tt := range []struct {
+		config   localconfig.LocalTopicConfiguration
+		attempts int
+		expected time.Duration
+	}{
+		{config: localconfig.LocalTopicConfiguration{}, attempts: 1, expected: time.Second},
+		{config: localconfig.LocalTopicConfiguration{}, attempts: 2, expected: 2 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{}, attempts: 4, expected: 8 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{}, attempts: 6, expected: 32 * time.Second},
+		// capped at the default max backoff of 60 seconds
+		{config: localconfig.LocalTopicConfiguration{}, attempts: 7, expected: 60 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{}, attempts: 100, expected: 60 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{InitialBackoff: 5}, attempts: 1, expected: 5 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{InitialBackoff: 5}, attempts: 3, expected: 20 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{BackoffMultiplier: 3}, attempts: 3, expected: 9 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{BackoffMultiplier: 1.5}, attempts: 2, expected: 1500 * time.Millisecond},
+		{config: localconfig.LocalTopicConfiguration{InitialBackoff: 2, MaxBackoff: 10}, attempts: 3, expected: 8 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{InitialBackoff: 2, MaxBackoff: 10}, attempts: 4, expected: 10 * time.Second},
+		// the max backoff caps the first retry too
+		{config: localconfig.LocalTopicConfiguration{InitialBackoff: 30, MaxBackoff: 10}, attempts: 1, expected: 10 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{MaxBackoff: 300}, attempts: 9, expected: 256 * time.Second},
+		{config: localconfig.LocalTopicConfiguration{MaxBackoff: 300}, attempts: 10, expected: 300 * time.Second},
+	} {
+		t.Run(fmt.Sprintf("test retryBackoff: %d", i), func(t *testing.T) {
+			actual := retryBackoff(tt.config, tt.attempts)
+
+			if actual != tt.expected {
+				t.Errorf("expected a backoff of %s after %d attempts with %+v, got %s", tt.expected, tt.attempts, tt.config, actual)
+			}
+		})
+	}
+}
+
+func TestFanOutRetriesFailedServices(t *testing.T) {
+	previousBackoff := defaultInitialBackoff
+	defaultInitialBackoff = 10 * time.Millisecond
+
+	t.Cleanup(func() {
+		defaultInitialBackoff = previousBackoff
+	})
+
+	s := newTestTopicsService(t, localconfig.LocalConfiguration{
+		Topics: map[string]localconfig.LocalTopicConfiguration{
+			"orders": {MaxAttempts: 2},
+		},
+	})
+
+	actions := make(chan ActionState, 10)
+	s.SubscribeToAction(func(action ActionState) {
+		actions <- action
+	})
+
+	healthy := newTestSubscriberStream("healthy", "orders", true)
+	failing := newTestSubscriberStream("failing", "orders", false)
+
+	subscribe(t, s, healthy)
+	subscribe(t, s, failing)
+
+	_, err := s.Publish(context.TODO(), testPublishRequest(t, "orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first attempt goes to both services, the retry only to the service that failed
+	expected := []ActionState{
+		{
+			TopicName: "orders",
+			Payload:   `{"test":true}`,
+			Attempt:   1,
+			Subscribers: []SubscriberResult{
+				{ServiceName: "failing", Error: "subscriber returned an unsuccessful response"},
+				{ServiceName: "healthy", Delivered: true},
+			},
+		},
+		{
+			TopicName:    "orders",
+			Payload:      `{"test":true}`,
+			Attempt:      2,
+			DeadLettered: true,
+			Subscribers: []SubscriberResult{
+				{ServiceName: "failing", Error: "subscriber returned an unsuccessful response"},
+			},
+		},
+	}
+
+	for i, expectedAction := range expected {
+		select {
+		case action := <-actions:
+			// delays and durations depend on timing
+			action.Delay = 0
+			for j := range action.Subscribers {
+				action.Subscribers[j].Duration = 0
+			}
+
+			if !cmp.Equal(expectedAction, action) {
+				t.Errorf("attempt %d: %s", i+1, cmp.Diff(expectedAction, action))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for delivery attempt %d", i+1)
+		}
+	}
+
+	if healthy.Received() != 1 {
+		t.Errorf("expected the healthy service to receive the event once, got %d", healthy.Received())
+	}
+
+	if failing.Received() != 2 {
+		t.Errorf("expected the failing service to receive the event twice, got %d", failing.Received())
+	}
+
+	deadLetterEvents, err := s.GetDeadLetterEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deadLetterEvents) != 1 {
+		t.Fatalf("expected 1 dead-letter event, got %d", len(deadLetterEvents))
+	}
+
+	if deadLetterEvents[0].Attempts != 2 || !cmp.Equal([]string{"failing"}, deadLetterEvents[0].Services) {
+		t.Errorf("expected the event to be dead-lettered for the failing service after 2 attempts, got %+v", deadLetterEvents[0])
+	}
+
+	// the retry is removed from the delayed events once its attempt has been handled
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		delayedEvents, err := s.GetDelayedEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(delayedEvents) == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no delayed events, got %+v", delayedEvents)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -46,6 +46,8 @@ import (
 	"github.com/nitrictech/cli/pkg/cloud/apis"
 	"github.com/nitrictech/cli/pkg/cloud/gateway"
 	httpproxy "github.com/nitrictech/cli/pkg/cloud/http"
+	"github.com/nitrictech/cli/pkg/cloud/keyvalue"
+	"github.com/nitrictech/cli/pkg/cloud/queues"
 	"github.com/nitrictech/cli/pkg/cloud/resources"
 	"github.com/nitrictech/cli/pkg/cloud/schedules"
 	"github.com/nitrictech/cli/pkg/cloud/secrets"
@@ -80,6 +82,8 @@ type ScheduleSpec struct {
 	Expression string `json:"expression,omitempty"`
 	Rate       string `json:"rate,omitempty"`
 	Target     string `json:"target,omitempty"`
+	Paused     bool   `json:"paused"`
+	Timezone   string `json:"timezone,omitempty"`
 }
 
 type TopicSpec struct {
@@ -88,10 +92,16 @@ type TopicSpec struct {
 
 type QueueSpec struct {
 	*BaseResourceSpec
+
+	DeadLetterQueue string `json:"deadLetterQueue,omitempty"`
+	Depth           int    `json:"depth"`
+	InFlight        int    `json:"inFlight"`
 }
 
 type BucketSpec struct {
 	*BaseResourceSpec
+
+	Versioning bool `json:"versioning"`
 }
 
 type KeyValueSpec struct {
@@ -163,6 +173,10 @@ type Dashboard struct {
 	gatewayService         *gateway.LocalGatewayService
 	databaseService        *sql.LocalSqlServer
 	secretService          *secrets.DevSecretService
+	queueService           *queues.LocalQueuesService
+	topicsService          *topics.LocalTopicsAndSubscribersService
+	schedulesService       *schedules.LocalSchedulesService
+	keyValueService        *keyvalue.BoltDocService
 	apis                   []ApiSpec
 	apiUseHttps            bool
 	apiSecurityDefinitions map[string]map[string]*resourcespb.ApiSecurityDefinitionResource
@@ -178,6 +192,10 @@ type Dashboard struct {
 	notifications          []*NotifierSpec
 	httpProxies            []*HttpProxySpec
 	queues                 []*QueueSpec
+	queueState             queues.State
+	delayedEvents          []topics.DelayedEventInfo
+	deadLetterEvents       []topics.DeadLetterEventInfo
+	clock                  schedules.ClockState
 	policies               map[string]PolicySpec
 	envMap                 map[string]string
 
@@ -189,7 +207,9 @@ type Dashboard struct {
 	browserHasOpened bool
 	noBrowser        bool
 	browserLock      sync.Mutex
-	debouncedUpdate  func()
+	// historyLock serializes writes to the history files, each write reads and rewrites the whole file
+	historyLock     sync.Mutex
+	debouncedUpdate func()
 }
 
 type DashboardResponse struct {
@@ -209,6 +229,11 @@ type DashboardResponse struct {
 	Queues        []*QueueSpec       `json:"queues"`
 	HttpProxies   []*HttpProxySpec   `json:"httpProxies"`
 
+	DelayedEvents    []topics.DelayedEventInfo    `json:"delayedEvents"`
+	DeadLetterEvents []topics.DeadLetterEventInfo `json:"deadLetterEvents"`
+
+	Clock schedules.ClockState `json:"clock"`
+
 	Services []*ServiceSpec `json:"services"`
 
 	Policies            map[string]PolicySpec `json:"policies"`
@@ -315,6 +340,36 @@ func (d *Dashboard) updateResources(lrs resources.LocalResourcesState) {
 		}
 	}
 
+	// include dead-letter queues, which may not be declared as resources by any service
+	for queue, deadLetterQueue := range d.queueService.GetDeadLetterQueues() {
+		for _, spec := range d.queues {
+			if spec.Name == queue {
+				spec.DeadLetterQueue = deadLetterQueue
+			}
+		}
+
+		exists := lo.ContainsBy(d.queues, func(item *QueueSpec) bool {
+			return item.Name == deadLetterQueue
+		})
+
+		if !exists {
+			d.queues = append(d.queues, &QueueSpec{
+				BaseResourceSpec: &BaseResourceSpec{
+					Name:               deadLetterQueue,
+					RequestingServices: []string{},
+				},
+			})
+		}
+	}
+
+	if len(d.queues) > 0 {
+		slices.SortFunc(d.queues, func(a, b *QueueSpec) int {
+			return compare(a.Name, b.Name)
+		})
+	}
+
+	d.applyQueueState()
+
 	for bucketName, resource := range lrs.Buckets.GetAll() {
 		exists := lo.ContainsBy(d.buckets, func(item *BucketSpec) bool {
 			return item.Name == bucketName
@@ -326,6 +381,7 @@ func (d *Dashboard) updateResources(lrs resources.LocalResourcesState) {
 					Name:               bucketName,
 					RequestingServices: resource.RequestingServices,
 				},
+				Versioning: d.storageService.VersioningEnabled(bucketName),
 			})
 		}
 	}
@@ -543,6 +599,8 @@ func (d *Dashboard) updateSchedules(state schedules.State) {
 			Expression: srvc.Schedule.GetCron().GetExpression(),
 			Rate:       srvc.Schedule.GetEvery().GetRate(),
 			Target:     srvc.ServiceName,
+			Paused:     srvc.Paused,
+			Timezone:   srvc.Timezone,
 		})
 	}
 
@@ -630,6 +688,54 @@ func (d *Dashboard) updateSqlDatabases(state sql.State) {
 	d.refresh()
 }
 
+func (d *Dashboard) updateQueues(state queues.State) {
+	d.resourcesLock.Lock()
+	defer d.resourcesLock.Unlock()
+
+	d.queueState = state
+
+	d.applyQueueState()
+
+	d.refresh()
+}
+
+func (d *Dashboard) updateDelayedEvents(delayedEvents []topics.DelayedEventInfo) {
+	d.resourcesLock.Lock()
+	defer d.resourcesLock.Unlock()
+
+	d.delayedEvents = delayedEvents
+
+	d.refresh()
+}
+
+func (d *Dashboard) updateClock(clock schedules.ClockState) {
+	d.resourcesLock.Lock()
+	defer d.resourcesLock.Unlock()
+
+	d.clock = clock
+
+	d.refresh()
+}
+
+func (d *Dashboard) updateDeadLetterEvents(deadLetterEvents []topics.DeadLetterEventInfo) {
+	d.resourcesLock.Lock()
+	defer d.resourcesLock.Unlock()
+
+	d.deadLetterEvents = deadLetterEvents
+
+	d.refresh()
+}
+
+// applyQueueState sets the depth and in-flight count of each queue spec from the latest queue state
+func (d *Dashboard) applyQueueState() {
+	for _, spec := range d.queues {
+		queueState := d.queueState[spec.Name]
+
+		spec.Depth = queueState.Depth
+		spec.InFlight = queueState.InFlight
+	}
+}
+
 func (d *Dashboard) refresh() {
 	if !d.noBrowser && !d.browserHasOpened {
 		d.openBrowser()
@@ -717,6 +823,8 @@ func (d *Dashboard) Start() error {
 
 	http.HandleFunc("/api/history", d.createHistoryHttpHandler())
 
+	http.HandleFunc("/api/history/replay", d.createHistoryReplayHandler())
+
 	// Define an API route under /call to proxy communication between app and apis
 	http.HandleFunc("/api/call/", d.createCallProxyHttpHandler())
 
@@ -726,6 +834,18 @@ func (d *Dashboard) Start() error {
 
 	http.HandleFunc("/api/secrets", d.createSecretsHandler())
 
+	http.HandleFunc("/api/queues", d.createQueuesHandler())
+
+	http.HandleFunc("/api/delayed-events", d.createDelayedEventsHandler())
+
+	http.HandleFunc("/api/dead-letter-events", d.createDeadLetterEventsHandler())
+
+	http.HandleFunc("/api/schedules", d.createSchedulesHandler())
+
+	http.HandleFunc("/api/clock", d.createClockHandler())
+
+	http.HandleFunc("/api/kv", d.createKeyValueHandler())
+
 	http.HandleFunc("/api/sql/migrate", d.createApplySqlMigrationsHandler(aferoFs, false))
 
 	// handle websockets
@@ -831,15 +951,18 @@ func (d *Dashboard) sendStackUpdate() error {
 		Subscriptions:       d.subscriptions,
 		Notifications:       d.notifications,
 		HttpProxies:         d.httpProxies,
+		DelayedEvents:       d.delayedEvents,
+		DeadLetterEvents:    d.deadLetterEvents,
+		Clock:               d.clock,
 		ProjectName:         d.project.Name,
 		ApiAddresses:        d.gatewayService.GetApiAddresses(),
 		WebsocketAddresses:  d.gatewayService.GetWebsocketAddresses(),
 		HttpWorkerAddresses: d.gatewayService.GetHttpWorkerAddresses(),
 		TriggerAddress:      d.gatewayService.GetTriggerAddress(),
-		// StorageAddress:      d.storageService.GetStorageEndpoint(),
-		CurrentVersion: currentVersion,
-		LatestVersion:  latestVersion,
-		Connected:      d.isConnected(),
+		StorageAddress:      d.storageService.GetStorageEndpoint(),
+		CurrentVersion:      currentVersion,
+		LatestVersion:       latestVersion,
+		Connected:           d.isConnected(),
 	}
 
 	// Encode the response as JSON
@@ -897,6 +1020,10 @@ func New(noBrowser bool, localCloud *cloud.LocalCloud, project *project.Project)
 		gatewayService:         localCloud.Gateway,
 		databaseService:        localCloud.Databases,
 		secretService:          localCloud.Secrets,
+		queueService:           localCloud.Queues,
+		topicsService:          localCloud.Topics,
+		schedulesService:       localCloud.Schedules,
+		keyValueService:        localCloud.KeyValue,
 		apis:                   []ApiSpec{},
 		apiUseHttps:            localCloud.Gateway.ApiTlsCredentials != nil,
 		apiSecurityDefinitions: map[string]map[string]*resourcespb.ApiSecurityDefinitionResource{},
@@ -915,6 +1042,9 @@ func New(noBrowser bool, localCloud *cloud.LocalCloud, project *project.Project)
 		sqlDatabases:           []*SQLDatabaseSpec{},
 		secrets:                []*SecretSpec{},
 		queues:                 []*QueueSpec{},
+		queueState:             queues.State{},
+		delayedEvents:          []topics.DelayedEventInfo{},
+		deadLetterEvents:       []topics.DeadLetterEventInfo{},
 		httpProxies:            []*HttpProxySpec{},
 		policies:               map[string]PolicySpec{},
 		websocketsInfo:         map[string]*websockets.WebsocketInfo{},
@@ -941,12 +1071,46 @@ func New(noBrowser bool, localCloud *cloud.LocalCloud, project *project.Project)
 	localCloud.Storage.SubscribeToState(dash.updateBucketNotifications)
 	localCloud.Http.SubscribeToState(dash.updateHttpProxies)
 	localCloud.Databases.SubscribeToState(dash.updateSqlDatabases)
+	localCloud.Queues.SubscribeToState(dash.updateQueues)
+
+	// queues are persisted, so may already contain messages from a previous run
+	queueState, err := localCloud.Queues.GetState()
+	if err != nil {
+		return nil, err
+	}
+
+	dash.queueState = queueState
+
+	localCloud.Topics.SubscribeToDelayedEvents(dash.updateDelayedEvents)
+
+	// delayed events are persisted, so may still be pending from a previous run
+	delayedEvents, err := localCloud.Topics.GetDelayedEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	dash.delayedEvents = delayedEvents
+
+	localCloud.Topics.SubscribeToDeadLetterEvents(dash.updateDeadLetterEvents)
+
+	deadLetterEvents, err := localCloud.Topics.GetDeadLetterEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	dash.deadLetterEvents = deadLetterEvents
+
+	localCloud.Schedules.SubscribeToClock(dash.updateClock)
+
+	dash.clock = localCloud.Schedules.GetClockState()
 
 	// subscribe to history events from gateway
 	localCloud.Apis.SubscribeToAction(dash.handleApiHistory)
 	localCloud.Topics.SubscribeToAction(dash.handleTopicsHistory)
 	localCloud.Schedules.SubscribeToAction(dash.handleSchedulesHistory)
 	localCloud.Batch.SubscribeToAction(dash.handleBatchJobsHistory)
+	localCloud.Queues.SubscribeToAction(dash.handleQueuesHistory)
+	localCloud.Storage.SubscribeToAction(dash.handleNotificationsHistory)
 	localCloud.Websockets.SubscribeToAction(dash.handleWebsocketEvents)
 
 	return dash, nil
@@ -1,11 +1,11 @@
 import { useCallback } from 'react'
 import useSWR from 'swr'
 import { fetcher } from './fetcher'
-import type { BucketFile } from '../../types'
+import type { BucketFileList } from '../../types'
 import { STORAGE_API } from '../constants'
 
 export const useBucket = (bucket?: string, prefix?: string) => {
-  const { data, mutate } = useSWR<BucketFile[]>(
+  const { data, mutate } = useSWR<BucketFileList>(
     bucket && prefix
       ? `${STORAGE_API}?action=list-files&bucket=${bucket}`
       : null,
@@ -42,7 +42,7 @@ export const useBucket = (bucket?: string, prefix?: string) => {
   )
 
   return {
-    data,
+    data: data?.files,
     mutate,
     deleteFile,
     writeFile,
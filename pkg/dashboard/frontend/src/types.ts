@@ -26,6 +26,29 @@ export interface Api extends BaseResource {
 
 export type KeyValue = BaseResource
 
+export interface KeyValueKey {
+  key: string
+  expiresAt?: string
+  /** remaining time to live in seconds */
+  ttl?: number
+}
+
+export interface KeyValueKeyPage {
+  keys: KeyValueKey[]
+  nextContinuationToken?: string
+}
+
+export interface KeyValueValue extends KeyValueKey {
+  value: Record<string, any>
+}
+
+export interface KeyValueExportedKey {
+  store: string
+  key: string
+  value: Record<string, any>
+  expiresAt?: string
+}
+
 export interface SQLDatabase extends BaseResource {
   connectionString: string
   status: 'starting' | 'active' | 'building migrations' | 'applying migrations'
@@ -40,6 +63,8 @@ export interface Schedule extends BaseResource {
   expression?: string
   rate?: string
   target: string
+  paused: boolean
+  timezone?: string
 }
 
 export type Topic = BaseResource
@@ -55,6 +80,8 @@ export interface History {
   schedules: EventHistoryItem[]
   topics: EventHistoryItem[]
   jobs: EventHistoryItem[]
+  queues: QueueHistoryItem[]
+  notifications: NotificationHistoryItem[]
 }
 
 export type WebsocketEvent = 'connect' | 'disconnect' | 'message'
@@ -76,9 +103,23 @@ export interface WebSocketsInfo {
   [socket: string]: WebSocketInfoData
 }
 
-export type Bucket = BaseResource
+export interface Bucket extends BaseResource {
+  versioning: boolean
+}
+
+export interface Queue extends BaseResource {
+  deadLetterQueue?: string
+  depth: number
+  inFlight: number
+}
 
-export type Queue = BaseResource
+export interface QueueMessage {
+  id: number
+  payload: string
+  receiveCount: number
+  leaseId?: string
+  leaseExpiry?: string
+}
 
 export type Secret = BaseResource
 
@@ -105,6 +146,36 @@ interface Resource {
   name: string
   type: ResourceType
 }
+export interface DelayedEvent {
+  id: string
+  topicName: string
+  payload: string
+  publishedAt: string
+  deliverAt: string
+  waitingForSubscribers: boolean
+  attempts: number
+  /** delayed for retry after a failed delivery, rather than published with a delay */
+  retry: boolean
+  /** the services the event is delivered to, every subscriber when omitted */
+  services?: string[]
+}
+
+export interface DeadLetterEvent {
+  id: string
+  topicName: string
+  payload: string
+  attempts: number
+  failedAt: string
+  error?: string
+  /** the services that failed to handle the event */
+  services?: string[]
+}
+
+export interface Clock {
+  now: string
+  offset: number
+}
+
 export interface Policy extends BaseResource {
   principals: Resource[]
   actions: string[]
@@ -127,6 +198,9 @@ export interface WebSocketResponse {
   httpProxies: HttpProxy[]
   websockets: WebSocket[]
   queues: Queue[]
+  delayedEvents: DelayedEvent[]
+  deadLetterEvents: DeadLetterEvent[]
+  clock: Clock
   policies: {
     [name: string]: Policy
   }
@@ -174,6 +248,27 @@ export interface APIResponse {
 
 export interface BucketFile {
   key: string
+  contentType: string
+  size: number
+  lastModified: string
+  etag: string
+}
+
+export interface BucketFileVersion {
+  versionId: string
+  key: string
+  contentType: string
+  size: number
+  lastModified: string
+  etag: string
+  archivedAt: string
+  deleted: boolean
+}
+
+export interface BucketFileList {
+  files: BucketFile[]
+  prefixes: string[]
+  nextContinuationToken?: string
 }
 
 // HISTORY //
@@ -186,6 +281,7 @@ export interface LocalStorageHistoryItem {
 
 /** History that is received from the CLI web socket */
 export interface HistoryItem<T> {
+  id?: string
   time: number
   event: T
 }
@@ -199,19 +295,63 @@ export type EventResource = Schedule | Topic | BatchJob
 
 export type TopicHistoryItem = HistoryItem<{
   name: string
+  delay?: number
   payload: string
   success: boolean
+  attempt?: number
+  error?: string
+  deadLettered?: boolean
+  subscribers?: TopicSubscriberResult[]
 }>
 
+export interface TopicSubscriberResult {
+  serviceName: string
+  delivered: boolean
+  error?: string
+  duration: number
+}
+
 export type BatchHistoryItem = HistoryItem<{
   name: string
   payload: string
   success: boolean
 }>
 
+export type QueueHistoryItem = HistoryItem<{
+  name: string
+  action:
+    | 'enqueue'
+    | 'dequeue'
+    | 'complete'
+    | 'lease-expired'
+    | 'extend-lease'
+    | 'dead-letter'
+    | 'purge'
+  payload?: string
+  leaseId?: string
+  serviceName?: string
+}>
+
+export type NotificationHistoryItem = HistoryItem<{
+  bucket: string
+  key: string
+  eventType: 'write' | 'delete'
+  serviceName: string
+  keyPrefixFilter?: string
+  success: boolean
+  error?: string
+  duration: number
+  synthetic?: boolean
+}>
+
 export type ScheduleHistoryItem = HistoryItem<{
   name: string
   success: boolean
+  skipped?: boolean
+  queued?: boolean
+  wait?: number
+  duration?: number
+  error?: string
 }>
 
 export type ApiHistoryItem = HistoryItem<{
@@ -17,28 +17,40 @@
 package dashboard
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/samber/lo"
 	"github.com/spf13/afero"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/nitrictech/cli/pkg/cloud/apis"
 	"github.com/nitrictech/cli/pkg/cloud/batch"
+	"github.com/nitrictech/cli/pkg/cloud/keyvalue"
+	"github.com/nitrictech/cli/pkg/cloud/queues"
 	"github.com/nitrictech/cli/pkg/cloud/schedules"
+	"github.com/nitrictech/cli/pkg/cloud/storage"
 	"github.com/nitrictech/cli/pkg/cloud/topics"
 	"github.com/nitrictech/cli/pkg/cloud/websockets"
 	base_http "github.com/nitrictech/nitric/cloud/common/runtime/gateway"
 	apispb "github.com/nitrictech/nitric/core/pkg/proto/apis/v1"
+	kvstorepb "github.com/nitrictech/nitric/core/pkg/proto/kvstore/v1"
+	queuespb "github.com/nitrictech/nitric/core/pkg/proto/queues/v1"
 	resourcespb "github.com/nitrictech/nitric/core/pkg/proto/resources/v1"
 	secretspb "github.com/nitrictech/nitric/core/pkg/proto/secrets/v1"
 	storagepb "github.com/nitrictech/nitric/core/pkg/proto/storage/v1"
@@ -81,28 +93,47 @@ func (d *Dashboard) handleStorage() func(http.ResponseWriter, *http.Request) {
 				return
 			}
 
-			resp, err := d.storageService.Read(ctx, &storagepb.StorageReadRequest{
-				BucketName: bucketName,
-				Key:        fileKey,
-			})
+			file, blobInfo, err := d.storageService.OpenBlob(bucketName, fileKey)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			defer file.Close()
+
+			w.Header().Set("Content-Type", blobInfo.ContentType)
+			w.Header().Set("ETag", blobInfo.ETag)
 
-			handleResponseWriter(w, resp.Body)
+			// streams the file, so large files aren't loaded into memory
+			http.ServeContent(w, r, filepath.Base(fileKey), blobInfo.LastModified, file)
 
 			return
 		case "list-files":
-			fileList, err := d.storageService.ListBlobs(ctx, &storagepb.StorageListBlobsRequest{
-				BucketName: bucketName,
+			maxKeys := 0
+
+			if maxKeysParam := r.URL.Query().Get("maxKeys"); maxKeysParam != "" {
+				var err error
+
+				maxKeys, err = strconv.Atoi(maxKeysParam)
+				if err != nil || maxKeys < 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					handleResponseWriter(w, []byte(`{"error": "maxKeys must be a positive number"}`))
+
+					return
+				}
+			}
+
+			fileList, err := d.storageService.ListBlobPage(bucketName, storage.ListBlobsOptions{
+				Prefix:            r.URL.Query().Get("prefix"),
+				Delimiter:         r.URL.Query().Get("delimiter"),
+				ContinuationToken: r.URL.Query().Get("continuationToken"),
+				MaxKeys:           maxKeys,
 			})
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			jsonResponse, err := json.Marshal(fileList.GetBlobs())
+			jsonResponse, err := json.Marshal(fileList)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
@@ -119,25 +150,125 @@ func (d *Dashboard) handleStorage() func(http.ResponseWriter, *http.Request) {
 				return
 			}
 
-			// Read the contents of the file
-			contents, err := io.ReadAll(r.Body)
+			err := d.storageService.WriteStream(ctx, bucketName, fileKey, r.Body, r.Header.Get("Content-Type"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			handleResponseWriter(w, []byte(`{"success": true}`))
+
+			return
+		case "list-versions":
+			// lists the versions of every file in the bucket when no fileKey is provided, including deleted files
+			versions, err := d.storageService.ListBlobVersions(bucketName, r.URL.Query().Get("fileKey"))
 			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				handleResponseWriter(w, []byte(fmt.Sprintf(`{"error": "%s"}`, err.Error())))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 
+			jsonResponse, err := json.Marshal(versions)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			_, err = d.storageService.Write(ctx, &storagepb.StorageWriteRequest{
-				BucketName: bucketName,
-				Key:        fileKey,
-				Body:       contents,
-			})
+			handleResponseWriter(w, jsonResponse)
+
+			return
+		case "restore-version":
+			fileKey := r.URL.Query().Get("fileKey")
+			versionId := r.URL.Query().Get("versionId")
+
+			if fileKey == "" || versionId == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				handleResponseWriter(w, []byte(`{"error": "fileKey and versionId are required for restore-version action"}`))
+
+				return
+			}
+
+			err := d.storageService.RestoreBlobVersion(ctx, bucketName, fileKey, versionId)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			handleResponseWriter(w, []byte(`{"success": true}`))
+
+			return
+		case "presign":
+			fileKey := r.URL.Query().Get("fileKey")
+			if fileKey == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				handleResponseWriter(w, []byte(`{"error": "fileKey is required for presign action"}`))
+
+				return
+			}
+
+			operation, err := storage.ParsePresignOperation(r.URL.Query().Get("operation"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				handleResponseWriter(w, []byte(`{"error": "operation must be one of read, write, delete or head"}`))
+
+				return
+			}
+
+			// matches the default expiry of the nitric SDKs
+			expiry := 600 * time.Second
+
+			if expiryParam := r.URL.Query().Get("expiry"); expiryParam != "" {
+				seconds, err := strconv.Atoi(expiryParam)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					handleResponseWriter(w, []byte(`{"error": "expiry must be a number of seconds"}`))
+
+					return
+				}
+
+				expiry = time.Duration(seconds) * time.Second
+			}
+
+			presignedUrl, err := d.storageService.PreSignUrlForOperation(bucketName, fileKey, operation, expiry)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			jsonResponse, err := json.Marshal(map[string]string{"url": presignedUrl})
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 
+			handleResponseWriter(w, jsonResponse)
+
+			return
+		case "trigger-notification":
+			fileKey := r.URL.Query().Get("fileKey")
+			if fileKey == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				handleResponseWriter(w, []byte(`{"error": "fileKey is required for trigger-notification action"}`))
+
+				return
+			}
+
+			var eventType storagepb.BlobEventType
+
+			switch r.URL.Query().Get("eventType") {
+			case "write":
+				eventType = storagepb.BlobEventType_Created
+			case "delete":
+				eventType = storagepb.BlobEventType_Deleted
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				handleResponseWriter(w, []byte(`{"error": "eventType must be write or delete"}`))
+
+				return
+			}
+
+			// notifies the listeners without changing the bucket
+			d.storageService.TriggerNotification(bucketName, fileKey, eventType)
+
 			handleResponseWriter(w, []byte(`{"success": true}`))
 
 			return
@@ -442,10 +573,10 @@ func (d *Dashboard) createSecretsHandler() func(http.ResponseWriter, *http.Reque
 	}
 }
 
-func (d *Dashboard) createHistoryHttpHandler() func(http.ResponseWriter, *http.Request) {
+func (d *Dashboard) createQueuesHandler() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "*")
 
 		if r.Method == "OPTIONS" {
@@ -453,30 +584,93 @@ func (d *Dashboard) createHistoryHttpHandler() func(http.ResponseWriter, *http.R
 			return
 		}
 
-		if r.Method == "DELETE" {
-			historyType := r.URL.Query().Get("type")
+		ctx := context.Background()
+		queueName := r.URL.Query().Get("queue")
+		action := r.URL.Query().Get("action")
 
-			err := d.DeleteHistoryRecord(RecordType(historyType))
+		if queueName == "" {
+			http.Error(w, "missing queue param", http.StatusBadRequest)
+			return
+		}
+
+		switch action {
+		case "peek":
+			limit := 0
+
+			if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+				var err error
+
+				limit, err = strconv.Atoi(limitParam)
+				if err != nil {
+					http.Error(w, "invalid limit param", http.StatusBadRequest)
+					return
+				}
+			}
+
+			messages, err := d.queueService.Peek(ctx, queueName, limit)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-		}
 
-		w.WriteHeader(http.StatusOK)
+			jsonResponse, err := json.Marshal(messages)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 
-		err := d.sendHistoryUpdate()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			w.Header().Set("Content-Type", "application/json")
+			handleResponseWriter(w, jsonResponse)
+		case "enqueue":
+			var payload map[string]interface{}
+
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			if err != nil {
+				http.Error(w, "payload must be a JSON object", http.StatusBadRequest)
+				return
+			}
+
+			structPayload, err := structpb.NewStruct(payload)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			_, err = d.queueService.Enqueue(ctx, &queuespb.QueueEnqueueRequest{
+				QueueName: queueName,
+				Messages: []*queuespb.QueueMessage{
+					{
+						Content: &queuespb.QueueMessage_StructPayload{
+							StructPayload: structPayload,
+						},
+					},
+				},
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		case "purge":
+			err := d.queueService.Purge(ctx, queueName)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "invalid action", http.StatusBadRequest)
 			return
 		}
 	}
 }
 
-func (d *Dashboard) handleWebsocketMessagesClear() func(http.ResponseWriter, *http.Request) {
+func (d *Dashboard) createDelayedEventsHandler() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "*")
 
 		if r.Method == "OPTIONS" {
@@ -484,105 +678,668 @@ func (d *Dashboard) handleWebsocketMessagesClear() func(http.ResponseWriter, *ht
 			return
 		}
 
-		if r.Method != "DELETE" {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		id := r.URL.Query().Get("id")
+		action := r.URL.Query().Get("action")
+
+		if id == "" {
+			http.Error(w, "missing id param", http.StatusBadRequest)
 			return
 		}
 
-		socketName := r.URL.Query().Get("socket")
+		var err error
 
-		if socketName == "" {
-			http.Error(w, "missing socket param", http.StatusBadRequest)
+		switch action {
+		case "release":
+			err = d.topicsService.ReleaseDelayedEvent(id)
+		case "cancel":
+			err = d.topicsService.CancelDelayedEvent(id)
+		default:
+			http.Error(w, "invalid action", http.StatusBadRequest)
 			return
 		}
 
-		if d.websocketsInfo[socketName] == nil {
-			http.Error(w, "socket not found", http.StatusNotFound)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		d.websocketsInfo[socketName].Messages = []websockets.WebsocketMessage{}
-
 		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (d *Dashboard) createDeadLetterEventsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		action := r.URL.Query().Get("action")
+
+		if id == "" {
+			http.Error(w, "missing id param", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+
+		switch action {
+		case "redeliver":
+			err = d.topicsService.RedeliverDeadLetterEvent(id)
+		case "delete":
+			err = d.topicsService.DeleteDeadLetterEvent(id)
+		default:
+			http.Error(w, "invalid action", http.StatusBadRequest)
+			return
+		}
 
-		err := d.sendWebsocketsUpdate()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+
+		w.WriteHeader(http.StatusOK)
 	}
 }
 
-func (d *Dashboard) handleApiHistory(state apis.ApiRequestState) {
-	var queryParams []Param
+func (d *Dashboard) createSchedulesHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
 
-	state.ReqCtx.QueryArgs().VisitAll(func(key []byte, val []byte) {
-		queryParams = append(queryParams, Param{
-			Key:   string(key),
-			Value: string(val),
-		})
-	})
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
-	err := d.writeHistoryRecord(&HistoryEvent[any]{
-		Time:       time.Now().UnixMilli(),
-		RecordType: API,
-		Event: ApiHistoryItem{
-			Api: state.Api,
-			Request: &RequestHistory{
-				Method:      string(state.ReqCtx.Request.Header.Method()),
-				Path:        string(state.ReqCtx.URI().PathOriginal()),
-				QueryParams: queryParams,
-				Headers:     base_http.HttpHeadersToMap(&state.ReqCtx.Request.Header),
-				Body:        state.ReqCtx.Request.Body(),
-				PathParams:  []Param{},
-			},
-			Response: &ResponseHistory{
-				Headers: lo.MapEntries(state.HttpResp.Headers, func(k string, v *apispb.HeaderValue) (string, []string) {
-					return k, v.Value
-				}),
-				Time:   time.Since(state.ReqCtx.ConnTime()).Milliseconds(),
-				Status: state.HttpResp.GetStatus(),
-				Data:   state.HttpResp.GetBody(),
-				Size:   len(state.HttpResp.GetBody()),
-			},
-		},
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-}
+		scheduleName := r.URL.Query().Get("name")
+		action := r.URL.Query().Get("action")
 
-func (d *Dashboard) handleWebsocketEvents(action websockets.WebsocketAction[websockets.EventItem]) {
-	if d.websocketsInfo[action.Name] == nil {
-		d.websocketsInfo[action.Name] = &websockets.WebsocketInfo{}
-	}
+		if scheduleName == "" {
+			http.Error(w, "missing name param", http.StatusBadRequest)
+			return
+		}
 
-	switch e := action.Event.(type) {
-	case websockets.WebsocketInfo:
-		d.websocketsInfo[action.Name].ConnectionCount = e.ConnectionCount
-	case websockets.WebsocketMessage:
-		d.websocketsInfo[action.Name].Messages = append([]websockets.WebsocketMessage{e}, d.websocketsInfo[action.Name].Messages...)
-	}
+		switch action {
+		case "next":
+			count := 5
 
-	err := d.sendWebsocketsUpdate()
-	if err != nil {
-		log.Fatal(err)
-	}
-}
+			if countParam := r.URL.Query().Get("count"); countParam != "" {
+				var err error
 
-func (d *Dashboard) handleTopicsHistory(action topics.ActionState) {
-	err := d.writeHistoryRecord(&HistoryEvent[any]{
-		Time:       time.Now().UnixMilli(),
-		RecordType: TOPIC,
-		Event: TopicHistoryItem{
-			Name:    action.TopicName,
-			Payload: action.Payload,
-			Success: action.Success,
-		},
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
+				count, err = strconv.Atoi(countParam)
+				if err != nil || count < 1 || count > 100 {
+					http.Error(w, "invalid count param, must be a number between 1 and 100", http.StatusBadRequest)
+					return
+				}
+			}
+
+			nextRuns, err := d.schedulesService.NextRuns(scheduleName, count)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			jsonResponse, err := json.Marshal(nextRuns)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			handleResponseWriter(w, jsonResponse)
+		case "pause", "resume":
+			var err error
+
+			if action == "pause" {
+				err = d.schedulesService.PauseSchedule(scheduleName)
+			} else {
+				err = d.schedulesService.ResumeSchedule(scheduleName)
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "invalid action", http.StatusBadRequest)
+		}
+	}
+}
+
+func (d *Dashboard) createKeyValueHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		ctx := context.Background()
+		storeName := r.URL.Query().Get("store")
+		action := r.URL.Query().Get("action")
+		key := r.URL.Query().Get("key")
+
+		w.Header().Set("Content-Type", "application/json")
+
+		// export defaults to every store and imports name the store of each key
+		if storeName == "" && action != "export" && action != "import" {
+			w.WriteHeader(http.StatusBadRequest)
+			handleResponseWriter(w, []byte(`{"error": "store is required"}`))
+
+			return
+		}
+
+		if storeName != "" {
+			if !keyvalue.ValidStoreName(storeName) {
+				w.WriteHeader(http.StatusBadRequest)
+				handleResponseWriter(w, []byte(`{"error": "invalid store name"}`))
+
+				return
+			}
+
+			// opening a store creates it, so only stores that are declared or already exist can be used
+			exists, err := d.keyValueStoreExists(storeName)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				handleResponseWriter(w, []byte(`{"error": "store not found"}`))
+
+				return
+			}
+		}
+
+		if key == "" && lo.Contains([]string{"read-value", "create-value", "write-value", "delete-value"}, action) {
+			w.WriteHeader(http.StatusBadRequest)
+			handleResponseWriter(w, []byte(`{"error": "key is required for value actions"}`))
+
+			return
+		}
+
+		switch action {
+		case "list-keys":
+			maxKeys := 0
+
+			if maxKeysParam := r.URL.Query().Get("maxKeys"); maxKeysParam != "" {
+				var err error
+
+				maxKeys, err = strconv.Atoi(maxKeysParam)
+				if err != nil || maxKeys < 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					handleResponseWriter(w, []byte(`{"error": "maxKeys must be a positive number"}`))
+
+					return
+				}
+			}
+
+			keyPage, err := d.keyValueService.ListKeyPage(storeName, keyvalue.ListKeysOptions{
+				Prefix:            r.URL.Query().Get("prefix"),
+				ContinuationToken: r.URL.Query().Get("continuationToken"),
+				MaxKeys:           maxKeys,
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			jsonResponse, err := json.Marshal(keyPage)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			handleResponseWriter(w, jsonResponse)
+		case "export":
+			format := keyvalue.ExportJSON
+
+			if formatParam := r.URL.Query().Get("format"); formatParam != "" {
+				var err error
+
+				format, err = keyvalue.ParseExportFormat(formatParam)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					handleResponseWriter(w, []byte(`{"error": "format must be json or ndjson"}`))
+
+					return
+				}
+			}
+
+			storeNames := []string{}
+			fileName := "kv-export"
+
+			if storeName != "" {
+				storeNames = append(storeNames, storeName)
+				fileName = storeName
+			}
+
+			// export to a buffer first, so a failed export can still return an error
+			var export bytes.Buffer
+
+			_, err := d.keyValueService.Export(&export, format, storeNames...)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if format == keyvalue.ExportNDJSON {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+			}
+
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", fileName, format))
+			handleResponseWriter(w, export.Bytes())
+		case "import":
+			count, err := d.keyValueService.Import(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+
+				jsonResponse, _ := json.Marshal(map[string]string{"error": err.Error()})
+				handleResponseWriter(w, jsonResponse)
+
+				return
+			}
+
+			jsonResponse, err := json.Marshal(map[string]int{"imported": count})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			handleResponseWriter(w, jsonResponse)
+		case "read-value":
+			value, err := d.keyValueService.GetKey(storeName, key)
+			if err != nil {
+				if errors.Is(err, keyvalue.ErrKeyNotFound) {
+					w.WriteHeader(http.StatusNotFound)
+					handleResponseWriter(w, []byte(`{"error": "key not found"}`))
+
+					return
+				}
+
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			jsonResponse, err := json.Marshal(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			handleResponseWriter(w, jsonResponse)
+		case "create-value", "write-value":
+			// values are JSON objects, as they are in the SDKs
+			var value map[string]interface{}
+
+			err := json.NewDecoder(r.Body).Decode(&value)
+			if err != nil || value == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				handleResponseWriter(w, []byte(`{"error": "value must be a JSON object"}`))
+
+				return
+			}
+
+			// create-value fails rather than overwriting an existing key
+			err = d.keyValueService.PutKey(storeName, key, value, action == "write-value")
+			if err != nil {
+				if errors.Is(err, keyvalue.ErrKeyExists) {
+					w.WriteHeader(http.StatusConflict)
+					handleResponseWriter(w, []byte(`{"error": "key already exists"}`))
+
+					return
+				}
+
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			handleResponseWriter(w, []byte(`{"success": true}`))
+		case "delete-value":
+			_, err := d.keyValueService.DeleteKey(ctx, &kvstorepb.KvStoreDeleteKeyRequest{
+				Ref: &kvstorepb.ValueRef{Store: storeName, Key: key},
+			})
+			if err != nil {
+				if status.Code(err) == codes.NotFound {
+					w.WriteHeader(http.StatusNotFound)
+					handleResponseWriter(w, []byte(`{"error": "key not found"}`))
+
+					return
+				}
+
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			handleResponseWriter(w, []byte(`{"success": true}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			handleResponseWriter(w, []byte(`{"error": "Invalid action"}`))
+		}
+	}
+}
+
+// keyValueStoreExists reports whether a store is declared by a service or exists on disk, e.g. from a previous run
+func (d *Dashboard) keyValueStoreExists(storeName string) (bool, error) {
+	storeName = strings.ToLower(storeName)
+
+	d.resourcesLock.Lock()
+	declared := lo.ContainsBy(d.stores, func(item *KeyValueSpec) bool {
+		return strings.ToLower(item.Name) == storeName
+	})
+	d.resourcesLock.Unlock()
+
+	if declared {
+		return true, nil
+	}
+
+	storeNames, err := d.keyValueService.StoreNames()
+	if err != nil {
+		return false, err
+	}
+
+	return lo.Contains(storeNames, storeName), nil
+}
+
+func (d *Dashboard) createClockHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		action := r.URL.Query().Get("action")
+
+		switch action {
+		case "advance":
+			duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+			if err != nil {
+				http.Error(w, "invalid duration param, must be a duration such as 90m or 24h", http.StatusBadRequest)
+				return
+			}
+
+			runs, err := d.schedulesService.AdvanceClock(duration)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			jsonResponse, err := json.Marshal(schedules.ClockAdvanceResult{
+				Runs:  runs,
+				Clock: d.schedulesService.GetClockState(),
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			handleResponseWriter(w, jsonResponse)
+		case "reset":
+			d.schedulesService.ResetClock()
+
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "invalid action", http.StatusBadRequest)
+		}
+	}
+}
+
+func (d *Dashboard) createHistoryHttpHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if r.Method == "DELETE" {
+			historyType := r.URL.Query().Get("type")
+
+			err := d.DeleteHistoryRecord(RecordType(historyType))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		err := d.sendHistoryUpdate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (d *Dashboard) createHistoryReplayHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		ctx := context.Background()
+		historyType := RecordType(r.URL.Query().Get("type"))
+		id := r.URL.Query().Get("id")
+
+		if id == "" {
+			http.Error(w, "missing id param", http.StatusBadRequest)
+			return
+		}
+
+		switch historyType {
+		case TOPIC:
+			record, err := FindHistoryRecord[TopicHistoryItem](d.project.Directory, TOPIC, id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+
+			payload := &structpb.Struct{}
+
+			err = payload.UnmarshalJSON([]byte(record.Event.Payload))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			err = d.gatewayService.PublishTopicMessage(ctx, record.Event.Name, payload)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case BATCHJOBS:
+			record, err := FindHistoryRecord[BatchHistoryItem](d.project.Directory, BATCHJOBS, id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+
+			payload := &structpb.Struct{}
+
+			err = payload.UnmarshalJSON([]byte(record.Event.Payload))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			err = d.gatewayService.SubmitBatchJob(ctx, record.Event.Name, payload)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case API:
+			record, err := FindHistoryRecord[ApiHistoryItem](d.project.Directory, API, id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+
+			_, err = d.gatewayService.ReplayApiRequest(record.Event.Api, record.Event.ReplayRequest())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		default:
+			http.Error(w, "invalid type, only topics, jobs and apis can be replayed", http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (d *Dashboard) handleWebsocketMessagesClear() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if r.Method != "DELETE" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		socketName := r.URL.Query().Get("socket")
+
+		if socketName == "" {
+			http.Error(w, "missing socket param", http.StatusBadRequest)
+			return
+		}
+
+		if d.websocketsInfo[socketName] == nil {
+			http.Error(w, "socket not found", http.StatusNotFound)
+			return
+		}
+
+		d.websocketsInfo[socketName].Messages = []websockets.WebsocketMessage{}
+
+		w.WriteHeader(http.StatusOK)
+
+		err := d.sendWebsocketsUpdate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func (d *Dashboard) handleApiHistory(state apis.ApiRequestState) {
+	var queryParams []Param
+
+	state.ReqCtx.QueryArgs().VisitAll(func(key []byte, val []byte) {
+		queryParams = append(queryParams, Param{
+			Key:   string(key),
+			Value: string(val),
+		})
+	})
+
+	err := d.writeHistoryRecord(&HistoryEvent[any]{
+		Time:       time.Now().UnixMilli(),
+		RecordType: API,
+		Event: ApiHistoryItem{
+			Api: state.Api,
+			Request: &RequestHistory{
+				Method:      string(state.ReqCtx.Request.Header.Method()),
+				Path:        string(state.ReqCtx.URI().PathOriginal()),
+				QueryParams: queryParams,
+				Headers:     base_http.HttpHeadersToMap(&state.ReqCtx.Request.Header),
+				Body:        state.ReqCtx.Request.Body(),
+				PathParams:  []Param{},
+			},
+			Response: &ResponseHistory{
+				Headers: lo.MapEntries(state.HttpResp.Headers, func(k string, v *apispb.HeaderValue) (string, []string) {
+					return k, v.Value
+				}),
+				Time:   time.Since(state.ReqCtx.ConnTime()).Milliseconds(),
+				Status: state.HttpResp.GetStatus(),
+				Data:   state.HttpResp.GetBody(),
+				Size:   len(state.HttpResp.GetBody()),
+			},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (d *Dashboard) handleWebsocketEvents(action websockets.WebsocketAction[websockets.EventItem]) {
+	if d.websocketsInfo[action.Name] == nil {
+		d.websocketsInfo[action.Name] = &websockets.WebsocketInfo{}
+	}
+
+	switch e := action.Event.(type) {
+	case websockets.WebsocketInfo:
+		d.websocketsInfo[action.Name].ConnectionCount = e.ConnectionCount
+	case websockets.WebsocketMessage:
+		d.websocketsInfo[action.Name].Messages = append([]websockets.WebsocketMessage{e}, d.websocketsInfo[action.Name].Messages...)
+	}
+
+	err := d.sendWebsocketsUpdate()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (d *Dashboard) handleTopicsHistory(action topics.ActionState) {
+	err := d.writeHistoryRecord(&HistoryEvent[any]{
+		Time:       time.Now().UnixMilli(),
+		RecordType: TOPIC,
+		Event: TopicHistoryItem{
+			Name:         action.TopicName,
+			Delay:        action.Delay,
+			Payload:      action.Payload,
+			Success:      action.Success,
+			Attempt:      action.Attempt,
+			Error:        action.Error,
+			DeadLettered: action.DeadLettered,
+			Subscribers: lo.Map(action.Subscribers, func(result topics.SubscriberResult, _ int) TopicSubscriberHistoryItem {
+				return TopicSubscriberHistoryItem{
+					ServiceName: result.ServiceName,
+					Delivered:   result.Delivered,
+					Error:       result.Error,
+					Duration:    result.Duration.Milliseconds(),
+				}
+			}),
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 func (d *Dashboard) handleSchedulesHistory(action schedules.ActionState) {
@@ -590,8 +1347,13 @@ func (d *Dashboard) handleSchedulesHistory(action schedules.ActionState) {
 		Time:       time.Now().UnixMilli(),
 		RecordType: SCHEDULE,
 		Event: ScheduleHistoryItem{
-			Name:    action.ScheduleName,
-			Success: action.Success,
+			Name:     action.ScheduleName,
+			Success:  action.Success,
+			Skipped:  action.Skipped,
+			Queued:   action.Queued,
+			Wait:     action.Wait.Milliseconds(),
+			Duration: action.Duration.Milliseconds(),
+			Error:    action.Error,
 		},
 	})
 	if err != nil {
@@ -599,6 +1361,23 @@ func (d *Dashboard) handleSchedulesHistory(action schedules.ActionState) {
 	}
 }
 
+func (d *Dashboard) handleQueuesHistory(action queues.ActionState) {
+	err := d.writeHistoryRecord(&HistoryEvent[any]{
+		Time:       time.Now().UnixMilli(),
+		RecordType: QUEUE,
+		Event: QueueHistoryItem{
+			Name:        action.QueueName,
+			Action:      string(action.Action),
+			Payload:     action.Payload,
+			LeaseId:     action.LeaseId,
+			ServiceName: action.ServiceName,
+		},
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
 func (d *Dashboard) handleBatchJobsHistory(action batch.ActionState) {
 	err := d.writeHistoryRecord(&HistoryEvent[any]{
 		Time:       time.Now().UnixMilli(),
@@ -613,3 +1392,29 @@ func (d *Dashboard) handleBatchJobsHistory(action batch.ActionState) {
 		log.Fatal(err)
 	}
 }
+
+func (d *Dashboard) handleNotificationsHistory(action storage.ActionState) {
+	eventType := "write"
+	if action.EventType == storagepb.BlobEventType_Deleted {
+		eventType = "delete"
+	}
+
+	err := d.writeHistoryRecord(&HistoryEvent[any]{
+		Time:       time.Now().UnixMilli(),
+		RecordType: NOTIFICATION,
+		Event: NotificationHistoryItem{
+			Bucket:          action.BucketName,
+			Key:             action.Key,
+			EventType:       eventType,
+			ServiceName:     action.ServiceName,
+			KeyPrefixFilter: action.KeyPrefixFilter,
+			Success:         action.Success,
+			Error:           action.Error,
+			Duration:        action.Duration.Milliseconds(),
+			Synthetic:       action.Synthetic,
+		},
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
@@ -23,16 +23,22 @@ import (
 	"log"
 	"os"
 
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	"github.com/nitrictech/cli/pkg/cloud/gateway"
 	"github.com/nitrictech/cli/pkg/paths"
 )
 
 const AddRecordTopic = "history:addrecord"
 
 type HistoryEvents struct {
-	ScheduleHistory []*HistoryEvent[ScheduleHistoryItem] `json:"schedules"`
-	TopicHistory    []*HistoryEvent[TopicHistoryItem]    `json:"topics"`
-	ApiHistory      []*HistoryEvent[ApiHistoryItem]      `json:"apis"`
-	BatchHistory    []*HistoryEvent[BatchHistoryItem]    `json:"jobs"`
+	ScheduleHistory     []*HistoryEvent[ScheduleHistoryItem]     `json:"schedules"`
+	TopicHistory        []*HistoryEvent[TopicHistoryItem]        `json:"topics"`
+	ApiHistory          []*HistoryEvent[ApiHistoryItem]          `json:"apis"`
+	BatchHistory        []*HistoryEvent[BatchHistoryItem]        `json:"jobs"`
+	QueueHistory        []*HistoryEvent[QueueHistoryItem]        `json:"queues"`
+	NotificationHistory []*HistoryEvent[NotificationHistoryItem] `json:"notifications"`
 }
 
 type RecordType string
@@ -42,22 +48,40 @@ const (
 	TOPIC     RecordType = "topics"
 	SCHEDULE  RecordType = "schedules"
 	BATCHJOBS RecordType = "jobs"
+	QUEUE     RecordType = "queues"
+	// bucket notifications
+	NOTIFICATION RecordType = "notifications"
 )
 
 type HistoryItem interface {
 	ApiHistoryItem | TopicHistoryItem | ScheduleHistoryItem | any
 }
 type HistoryEvent[Event HistoryItem] struct {
+	// Id uniquely identifies the record, so it can be replayed
+	Id         string     `json:"id,omitempty"`
 	Time       int64      `json:"time,omitempty"`
 	Event      Event      `json:"event"`
 	RecordType RecordType `json:"-"`
 }
 
 type TopicHistoryItem struct {
-	Name    string `json:"name,omitempty"`
-	Delay   int    `json:"delay,omitempty"`
-	Payload string `json:"payload,omitempty"`
-	Success bool   `json:"success,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Delay        int    `json:"delay,omitempty"`
+	Payload      string `json:"payload,omitempty"`
+	Success      bool   `json:"success,omitempty"`
+	Attempt      int    `json:"attempt,omitempty"`
+	Error        string `json:"error,omitempty"`
+	DeadLettered bool   `json:"deadLettered,omitempty"`
+	// Subscribers is the outcome of the delivery for each subscribing service
+	Subscribers []TopicSubscriberHistoryItem `json:"subscribers,omitempty"`
+}
+
+type TopicSubscriberHistoryItem struct {
+	ServiceName string `json:"serviceName"`
+	Delivered   bool   `json:"delivered"`
+	Error       string `json:"error,omitempty"`
+	// Duration of the delivery in milliseconds
+	Duration int64 `json:"duration"`
 }
 
 type BatchHistoryItem struct {
@@ -66,9 +90,40 @@ type BatchHistoryItem struct {
 	Success bool   `json:"success,omitempty"`
 }
 
+type QueueHistoryItem struct {
+	Name        string `json:"name,omitempty"`
+	Action      string `json:"action,omitempty"`
+	Payload     string `json:"payload,omitempty"`
+	LeaseId     string `json:"leaseId,omitempty"`
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+type NotificationHistoryItem struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// The blob event, write or delete
+	EventType   string `json:"eventType"`
+	ServiceName string `json:"serviceName"`
+	// The key prefix filter of the listener the notification matched
+	KeyPrefixFilter string `json:"keyPrefixFilter,omitempty"`
+	Success         bool   `json:"success"`
+	Error           string `json:"error,omitempty"`
+	// Duration of the delivery in milliseconds
+	Duration int64 `json:"duration"`
+	// The notification was fired from the dashboard rather than a change to the bucket
+	Synthetic bool `json:"synthetic,omitempty"`
+}
+
 type ScheduleHistoryItem struct {
 	Name    string `json:"name,omitempty"`
 	Success bool   `json:"success,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Queued  bool   `json:"queued,omitempty"`
+	// Wait of a queued run for the previous run to complete in milliseconds
+	Wait int64 `json:"wait,omitempty"`
+	// Duration of the run in milliseconds
+	Duration int64  `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
 type ApiHistoryItem struct {
@@ -77,6 +132,23 @@ type ApiHistoryItem struct {
 	Response *ResponseHistory `json:"response"`
 }
 
+// ReplayRequest returns the recorded request, ready to be sent to the API again
+func (a ApiHistoryItem) ReplayRequest() *gateway.ApiReplayRequest {
+	query := map[string][]string{}
+
+	for _, param := range a.Request.QueryParams {
+		query[param.Key] = append(query[param.Key], param.Value)
+	}
+
+	return &gateway.ApiReplayRequest{
+		Method:  a.Request.Method,
+		Path:    a.Request.Path,
+		Query:   query,
+		Headers: a.Request.Headers,
+		Body:    a.Request.Body,
+	}
+}
+
 type Param struct {
 	Key   string `json:"key,omitempty"`
 	Value string `json:"value,omitempty"`
@@ -104,7 +176,19 @@ func NewHistoryError(recordType RecordType, historyFile string) error {
 	return fmt.Errorf("could not write %s history to the JSON file '%s' due to a formatting issue. Please check the file's formatting and ensure it follows the correct JSON structure, or reset the history by deleting the file", recordType, historyFile)
 }
 
+// historyRecordLimits caps the number of records kept for record types that are written frequently, the oldest records are dropped first
+var historyRecordLimits = map[RecordType]int{
+	QUEUE: 1000,
+}
+
 func (d *Dashboard) writeHistoryRecord(historyRecord *HistoryEvent[any]) error {
+	if historyRecord.Id == "" {
+		historyRecord.Id = uuid.New().String()
+	}
+
+	d.historyLock.Lock()
+	defer d.historyLock.Unlock()
+
 	historyFile, err := paths.NitricHistoryFile(d.project.Directory, string(historyRecord.RecordType))
 	if err != nil {
 		return err
@@ -117,6 +201,10 @@ func (d *Dashboard) writeHistoryRecord(historyRecord *HistoryEvent[any]) error {
 
 	existingRecords = append(existingRecords, historyRecord)
 
+	if limit, ok := historyRecordLimits[historyRecord.RecordType]; ok && len(existingRecords) > limit {
+		existingRecords = existingRecords[len(existingRecords)-limit:]
+	}
+
 	data, err := json.Marshal(existingRecords)
 	if err != nil {
 		return NewHistoryError(historyRecord.RecordType, historyFile)
@@ -136,6 +224,9 @@ func (d *Dashboard) writeHistoryRecord(historyRecord *HistoryEvent[any]) error {
 }
 
 func (d *Dashboard) DeleteHistoryRecord(recordType RecordType) error {
+	d.historyLock.Lock()
+	defer d.historyLock.Unlock()
+
 	historyFile, err := paths.NitricHistoryFile(d.project.Directory, string(recordType))
 	if err != nil {
 		return err
@@ -165,11 +256,23 @@ func (d *Dashboard) ReadAllHistoryRecords() (*HistoryEvents, error) {
 		return nil, fmt.Errorf("error occurred reading batch job history: %w", err)
 	}
 
+	queues, err := ReadHistoryRecords[QueueHistoryItem](d.project.Directory, QUEUE)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred reading queue history: %w", err)
+	}
+
+	notifications, err := ReadHistoryRecords[NotificationHistoryItem](d.project.Directory, NOTIFICATION)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred reading notification history: %w", err)
+	}
+
 	return &HistoryEvents{
-		ScheduleHistory: schedules,
-		TopicHistory:    topics,
-		ApiHistory:      apis,
-		BatchHistory:    jobs,
+		ScheduleHistory:     schedules,
+		TopicHistory:        topics,
+		ApiHistory:          apis,
+		BatchHistory:        jobs,
+		QueueHistory:        queues,
+		NotificationHistory: notifications,
 	}, nil
 }
 
@@ -202,3 +305,20 @@ func ReadHistoryRecords[T HistoryItem](projectDir string, recordType RecordType)
 
 	return history, nil
 }
+
+// FindHistoryRecord returns the history record with the given id
+func FindHistoryRecord[T HistoryItem](projectDir string, recordType RecordType, id string) (*HistoryEvent[T], error) {
+	history, err := ReadHistoryRecords[T](projectDir, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	record, found := lo.Find(history, func(item *HistoryEvent[T]) bool {
+		return item.Id == id
+	})
+	if !found {
+		return nil, fmt.Errorf("%s history record %s not found", recordType, id)
+	}
+
+	return record, nil
+}
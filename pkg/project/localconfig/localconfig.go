@@ -29,9 +29,55 @@ type LocalResourceConfiguration struct {
 	Port int `yaml:"port"`
 }
 
+type LocalQueueConfiguration struct {
+	// The number of times a message can be received before it is moved to the dead-letter queue
+	MaxReceiveCount int `yaml:"maxReceiveCount"`
+	// The name of the queue that exhausted messages are moved to
+	DeadLetterQueue string `yaml:"deadLetterQueue"`
+	// The number of seconds a dequeued message is hidden from other consumers
+	VisibilityTimeout int `yaml:"visibilityTimeout"`
+}
+
+type LocalTopicConfiguration struct {
+	// The maximum number of delivery attempts, including the first, before an event is moved to the dead-letter store
+	MaxAttempts int `yaml:"maxAttempts"`
+	// The number of seconds to wait before the first retry, defaults to 1
+	InitialBackoff int `yaml:"initialBackoff"`
+	// The maximum number of seconds to wait between retries, defaults to 60
+	MaxBackoff int `yaml:"maxBackoff"`
+	// The factor the wait increases by after each failed attempt, defaults to 2
+	BackoffMultiplier float64 `yaml:"backoffMultiplier"`
+}
+
+type LocalScheduleConfiguration struct {
+	// The IANA timezone the cron expression is evaluated in, e.g. Australia/Sydney, overrides the project timezone
+	Timezone string `yaml:"timezone"`
+	// What to do when the schedule is due while its previous run is still in progress, one of allow (default), skip or queue
+	Overlap string `yaml:"overlap"`
+}
+
+type LocalBucketConfiguration struct {
+	// Keep the previous versions of overwritten and deleted files, so they can be restored
+	Versioning bool `yaml:"versioning"`
+}
+
+type LocalKeyValueConfiguration struct {
+	// The number of seconds keys live for after they're set, keys don't expire when zero
+	TTL int `yaml:"ttl"`
+	// The attribute of a value that holds its expiry time in unix epoch seconds, overrides the ttl of the store
+	TTLAttribute string `yaml:"ttlAttribute"`
+}
+
 type LocalConfiguration struct {
+	// The IANA timezone cron schedules are evaluated in, defaults to UTC
+	Timezone   string                                `yaml:"timezone"`
 	Apis       map[string]LocalResourceConfiguration `yaml:"apis"`
 	Websockets map[string]LocalResourceConfiguration `yaml:"websockets"`
+	Queues     map[string]LocalQueueConfiguration    `yaml:"queues"`
+	Topics     map[string]LocalTopicConfiguration    `yaml:"topics"`
+	Schedules  map[string]LocalScheduleConfiguration `yaml:"schedules"`
+	Buckets    map[string]LocalBucketConfiguration   `yaml:"buckets"`
+	KeyValue   map[string]LocalKeyValueConfiguration `yaml:"keyvalue"`
 }
 
 const defaultLocalNitricYamlPath = "./local.nitric.yaml"